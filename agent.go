@@ -0,0 +1,190 @@
+// agent.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	AgentIdle        = "Idle"
+	AgentBuilding    = "Building"
+	AgentLostContact = "LostContact"
+	AgentMissing     = "Missing"
+	AgentCancelled   = "Cancelled"
+	AgentUnknown     = "Unknown"
+	AgentDisabled    = "Disabled"
+	AgentEnabled     = "Enabled"
+	AgentPending     = "Pending"
+
+	// UnknownFreeSpace is the free space of an agent that has not reported it.
+	UnknownFreeSpace = FreeSpace(-1)
+)
+
+// FreeSpace is the disk space in bytes available to an agent.
+type FreeSpace int64
+
+// UnmarshalJSON accepts both a byte count and the "unknown" string Gocd reports for idle agents.
+func (space *FreeSpace) UnmarshalJSON(data []byte) error {
+	var bytes int64
+	if err := json.Unmarshal(data, &bytes); err == nil {
+		*space = FreeSpace(bytes)
+		return nil
+	}
+
+	*space = UnknownFreeSpace
+	return nil
+}
+
+// AgentEnvironments are the names of the environments an agent belongs to.
+type AgentEnvironments []string
+
+// UnmarshalJSON accepts both plain names and the environment objects used by newer Gocd servers.
+func (environments *AgentEnvironments) UnmarshalJSON(data []byte) error {
+	var names []string
+	if err := json.Unmarshal(data, &names); err == nil {
+		*environments = names
+		return nil
+	}
+
+	var objects []struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &objects); err != nil {
+		return err
+	}
+
+	*environments = AgentEnvironments{}
+	for _, object := range objects {
+		*environments = append(*environments, object.Name)
+	}
+	return nil
+}
+
+type AgentBuildDetails struct {
+	PipelineName string `json:"pipeline_name"`
+	StageName    string `json:"stage_name"`
+	JobName      string `json:"job_name"`
+}
+type Agent struct {
+	UUID            string             `json:"uuid"`
+	Hostname        string             `json:"hostname"`
+	IPAddress       string             `json:"ip_address"`
+	Sandbox         string             `json:"sandbox"`
+	OperatingSystem string             `json:"operating_system"`
+	FreeSpace       FreeSpace          `json:"free_space"`
+	ConfigState     string             `json:"agent_config_state"`
+	State           string             `json:"agent_state"`
+	BuildState      string             `json:"build_state"`
+	Resources       []string           `json:"resources"`
+	Environments    AgentEnvironments  `json:"environments"`
+	BuildDetails    *AgentBuildDetails `json:"build_details,omitempty"`
+}
+type agentsResponse struct {
+	Embedded struct {
+		Agents []Agent `json:"agents"`
+	} `json:"_embedded"`
+}
+
+// Status is the state of the agent, or Disabled when the agent is disabled regardless of its state.
+func (agent Agent) Status() string {
+	if strings.EqualFold(agent.ConfigState, AgentDisabled) {
+		return AgentDisabled
+	}
+
+	return agent.State
+}
+
+type resourceOperations struct {
+	Add    []string `json:"add"`
+	Remove []string `json:"remove"`
+}
+type agentOperations struct {
+	Resources *resourceOperations `json:"resources,omitempty"`
+}
+type agentsUpdate struct {
+	UUIDs       []string         `json:"uuids"`
+	ConfigState string           `json:"agent_config_state,omitempty"`
+	Operations  *agentOperations `json:"operations,omitempty"`
+}
+
+// Agents lists all agents known to Gocd.
+func (c Client) Agents(server string) ([]Agent, error) {
+	request, err := newGocdRequest("GET", strings.TrimRight(server, "/")+"/go/api/agents", c.accept(agentsAPI), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response agentsResponse
+	err = c.performJSON(request, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Embedded.Agents, nil
+}
+
+// Agent fetches a single agent by its UUID.
+func (c Client) Agent(server string, uuid string) (Agent, error) {
+	request, err := newGocdRequest("GET", strings.TrimRight(server, "/")+"/go/api/agents/"+url.PathEscape(uuid), c.accept(agentsAPI), nil)
+	if err != nil {
+		return Agent{}, err
+	}
+
+	var agent Agent
+	err = c.performJSON(request, &agent)
+	if err != nil {
+		return Agent{}, err
+	}
+
+	return agent, nil
+}
+
+// EnableAgents enables the agents with the given UUIDs.
+func (c Client) EnableAgents(server string, uuids ...string) error {
+	return c.updateAgents(server, agentsUpdate{UUIDs: uuids, ConfigState: AgentEnabled})
+}
+
+// DisableAgents disables the agents with the given UUIDs.
+func (c Client) DisableAgents(server string, uuids ...string) error {
+	return c.updateAgents(server, agentsUpdate{UUIDs: uuids, ConfigState: AgentDisabled})
+}
+
+// UpdateAgentResources adds and removes resources on all the agents with the given UUIDs.
+func (c Client) UpdateAgentResources(server string, uuids []string, add []string, remove []string) error {
+	operations := &agentOperations{Resources: &resourceOperations{Add: nonNil(add), Remove: nonNil(remove)}}
+	return c.updateAgents(server, agentsUpdate{UUIDs: uuids, Operations: operations})
+}
+
+func (c Client) updateAgents(server string, update agentsUpdate) error {
+	if len(update.UUIDs) == 0 {
+		return fmt.Errorf("error updating agents: no agents selected")
+	}
+
+	payload, err := json.Marshal(update)
+	if err != nil {
+		return fmt.Errorf("error marshalling agents update: %s", err)
+	}
+
+	request, err := newGocdRequest("PATCH", strings.TrimRight(server, "/")+"/go/api/agents", c.accept(agentsAPI), payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.perform(request)
+	return err
+}
+
+func nonNil(items []string) []string {
+	if items == nil {
+		return []string{}
+	}
+	return items
+}
@@ -0,0 +1,195 @@
+// agent_test.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/chiku/gocd"
+)
+
+const agentsResponse = `{
+	"_embedded": {
+		"agents": [{
+			"uuid": "agent-1",
+			"hostname": "builder-1",
+			"ip_address": "10.0.0.1",
+			"sandbox": "/var/lib/go-agent",
+			"operating_system": "Linux",
+			"free_space": 84983328768,
+			"agent_config_state": "Enabled",
+			"agent_state": "Building",
+			"build_state": "Building",
+			"resources": ["linux", "docker"],
+			"environments": [{ "name": "staging", "origin": { "type": "gocd" } }],
+			"build_details": { "pipeline_name": "Pipeline", "stage_name": "Stage", "job_name": "Job" }
+		}, {
+			"uuid": "agent-2",
+			"hostname": "builder-2",
+			"free_space": "unknown",
+			"agent_config_state": "Disabled",
+			"agent_state": "LostContact",
+			"resources": [],
+			"environments": ["prod"]
+		}]
+	}
+}`
+
+func TestClientAgents(t *testing.T) {
+	var accept string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept = r.Header.Get("Accept")
+		if r.URL.Path != "/go/api/agents" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(agentsResponse))
+	}))
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	agents, err := client.Agents(ts.URL)
+
+	if err != nil {
+		t.Fatalf("Expected no error listing agents: %s", err)
+	}
+	if accept != "application/vnd.go.cd.v7+json" {
+		t.Errorf("Expected versioned accept header, but was: %s", accept)
+	}
+	if len(agents) != 2 {
+		t.Fatalf("Expected 2 agents, but had %d agents: %#v", len(agents), agents)
+	}
+
+	expected := gocd.Agent{
+		UUID:            "agent-1",
+		Hostname:        "builder-1",
+		IPAddress:       "10.0.0.1",
+		Sandbox:         "/var/lib/go-agent",
+		OperatingSystem: "Linux",
+		FreeSpace:       84983328768,
+		ConfigState:     "Enabled",
+		State:           "Building",
+		BuildState:      "Building",
+		Resources:       []string{"linux", "docker"},
+		Environments:    gocd.AgentEnvironments{"staging"},
+		BuildDetails:    &gocd.AgentBuildDetails{PipelineName: "Pipeline", StageName: "Stage", JobName: "Job"},
+	}
+	if !reflect.DeepEqual(agents[0], expected) {
+		t.Errorf("Expected proper agent (%#v != %#v)", agents[0], expected)
+	}
+	if agents[0].Status() != gocd.AgentBuilding {
+		t.Errorf("Expected building agent, but was: %s", agents[0].Status())
+	}
+
+	agent := agents[1]
+	if agent.FreeSpace != gocd.UnknownFreeSpace || !reflect.DeepEqual(agent.Environments, gocd.AgentEnvironments{"prod"}) {
+		t.Errorf("Expected unknown free space and plain environments, but was: %#v", agent)
+	}
+	if agent.Status() != gocd.AgentDisabled {
+		t.Errorf("Expected disabled agent, but was: %s", agent.Status())
+	}
+}
+
+func TestClientAgent(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/go/api/agents/agent-1" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{ "uuid": "agent-1", "hostname": "builder-1", "agent_config_state": "Enabled", "agent_state": "Idle" }`))
+	}))
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	agent, err := client.Agent(ts.URL, "agent-1")
+
+	if err != nil {
+		t.Fatalf("Expected no error fetching agent: %s", err)
+	}
+	if agent.UUID != "agent-1" || agent.Status() != gocd.AgentIdle {
+		t.Errorf("Expected proper agent, but was: %#v", agent)
+	}
+}
+
+func fakeAgentUpdateServer(t *testing.T, method *string, body *string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("Expected no error reading request body: %s", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if r.URL.Path != "/go/api/agents" || r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		*method = r.Method
+		*body = string(content)
+		w.Write([]byte(`{ "message": "Updated agent(s)" }`))
+	}))
+}
+
+func TestClientEnableAgents(t *testing.T) {
+	var method, body string
+	ts := fakeAgentUpdateServer(t, &method, &body)
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	err := client.EnableAgents(ts.URL, "agent-1", "agent-2")
+
+	if err != nil {
+		t.Fatalf("Expected no error enabling agents: %s", err)
+	}
+	if method != "PATCH" || body != `{"uuids":["agent-1","agent-2"],"agent_config_state":"Enabled"}` {
+		t.Errorf("Expected proper update request, but was: %s %s", method, body)
+	}
+}
+
+func TestClientDisableAgents(t *testing.T) {
+	var method, body string
+	ts := fakeAgentUpdateServer(t, &method, &body)
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	err := client.DisableAgents(ts.URL, "agent-1")
+
+	if err != nil {
+		t.Fatalf("Expected no error disabling agents: %s", err)
+	}
+	if method != "PATCH" || body != `{"uuids":["agent-1"],"agent_config_state":"Disabled"}` {
+		t.Errorf("Expected proper update request, but was: %s %s", method, body)
+	}
+}
+
+func TestClientUpdateAgentResources(t *testing.T) {
+	var method, body string
+	ts := fakeAgentUpdateServer(t, &method, &body)
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	err := client.UpdateAgentResources(ts.URL, []string{"agent-1", "agent-2"}, []string{"java"}, nil)
+
+	if err != nil {
+		t.Fatalf("Expected no error updating agent resources: %s", err)
+	}
+	if method != "PATCH" || body != `{"uuids":["agent-1","agent-2"],"operations":{"resources":{"add":["java"],"remove":[]}}}` {
+		t.Errorf("Expected proper update request, but was: %s %s", method, body)
+	}
+}
+
+func TestClientUpdateAgentsWithoutAgents(t *testing.T) {
+	client := gocd.NewClient()
+	err := client.EnableAgents("http://localhost")
+
+	if err == nil || err.Error() != "error updating agents: no agents selected" {
+		t.Errorf("Expected error when no agents are selected, but was: %v", err)
+	}
+}
@@ -0,0 +1,356 @@
+// analytics.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+// Package analytics computes build health metrics from the history of Gocd pipelines.
+package analytics
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chiku/gocd"
+)
+
+const (
+	passed = "Passed"
+	failed = "Failed"
+)
+
+// StageRun is one run of a stage. Revision identifies the material revisions built, and may be empty when unknown.
+type StageRun struct {
+	Pipeline        string    `json:"pipeline"`
+	PipelineCounter int       `json:"pipeline_counter"`
+	Stage           string    `json:"stage"`
+	StageCounter    int       `json:"stage_counter"`
+	Result          string    `json:"result"`
+	Revision        string    `json:"revision,omitempty"`
+	Time            time.Time `json:"time"`
+}
+
+// Flake is a stage that failed and then passed on a rerun of the same revision.
+type Flake struct {
+	Pipeline        string `json:"pipeline"`
+	Stage           string `json:"stage"`
+	PipelineCounter int    `json:"pipeline_counter"`
+	StageCounter    int    `json:"stage_counter"`
+	Revision        string `json:"revision,omitempty"`
+}
+
+// Report is the build health of a pipeline, or of a stage when Stage is set.
+type Report struct {
+	Pipeline           string        `json:"pipeline"`
+	Stage              string        `json:"stage,omitempty"`
+	Runs               int           `json:"runs"`
+	Passed             int           `json:"passed"`
+	Failed             int           `json:"failed"`
+	PassRate           float64       `json:"pass_rate"`
+	Recoveries         int           `json:"recoveries"`
+	MeanTimeToRecovery time.Duration `json:"-"`
+	LongestRedStreak   int           `json:"longest_red_streak"`
+	Flakes             []Flake       `json:"flakes,omitempty"`
+}
+
+// BuildHealth holds the reports of every pipeline and stage, sorted by name.
+type BuildHealth struct {
+	Pipelines []Report `json:"pipelines"`
+	Stages    []Report `json:"stages"`
+}
+
+// Options limit the runs analysed to those in the Window before Now. A zero Window analyses every run,
+// and a zero Now is the time of the latest run.
+type Options struct {
+	Window time.Duration
+	Now    time.Time
+}
+
+// MarshalJSON writes the mean time to recovery in seconds.
+func (report Report) MarshalJSON() ([]byte, error) {
+	type plainReport Report
+	return json.Marshal(struct {
+		plainReport
+		MeanTimeToRecovery float64 `json:"mean_time_to_recovery_seconds"`
+	}{plainReport(report), report.MeanTimeToRecovery.Seconds()})
+}
+
+// IsFlaky tells if the stage, or any stage of the pipeline, passed on a rerun after failing.
+func (report Report) IsFlaky() bool {
+	return len(report.Flakes) > 0
+}
+
+// FromPipelineHistory lists the stage runs in the history of a pipeline, as fetched with Client.PipelineHistory.
+// Pipeline history only shows the latest run of each stage, so flakes fixed by rerunning a stage are missed;
+// use FromStageHistory to find those.
+func FromPipelineHistory(runs []gocd.PipelineRun) []StageRun {
+	var stageRuns []StageRun
+	for _, run := range runs {
+		revision := revisionOf(run.BuildCause)
+		for _, stage := range run.Stages {
+			if !stage.Scheduled {
+				continue
+			}
+			stageRuns = append(stageRuns, StageRun{
+				Pipeline:        run.Name,
+				PipelineCounter: run.Counter,
+				Stage:           stage.Name,
+				StageCounter:    stage.Counter,
+				Result:          stage.Result,
+				Revision:        revision,
+				Time:            stage.Time(),
+			})
+		}
+	}
+
+	return stageRuns
+}
+
+// FromStageHistory lists the stage runs in the history of a stage, as fetched with Client.StageHistory.
+// Stage history carries every rerun of the stage, but no revisions, so only reruns within a pipeline run
+// are known to build the same revision.
+func FromStageHistory(runs []gocd.StageHistoryRun) []StageRun {
+	var stageRuns []StageRun
+	for _, run := range runs {
+		if !run.Scheduled {
+			continue
+		}
+		stageRuns = append(stageRuns, StageRun{
+			Pipeline:        run.PipelineName,
+			PipelineCounter: run.PipelineCounter,
+			Stage:           run.Name,
+			StageCounter:    run.Counter,
+			Result:          run.Result,
+			Time:            run.Time(),
+		})
+	}
+
+	return stageRuns
+}
+
+// FromStageFeed lists the stage runs in stage feed entries. The feed carries no revisions, so only reruns
+// within a pipeline run are known to build the same revision.
+func FromStageFeed(entries []gocd.StageFeedEntry) []StageRun {
+	var stageRuns []StageRun
+	for _, entry := range entries {
+		stageRuns = append(stageRuns, StageRun{
+			Pipeline:        entry.Pipeline,
+			PipelineCounter: entry.PipelineCounter,
+			Stage:           entry.Stage,
+			StageCounter:    entry.StageCounter,
+			Result:          entry.Result,
+			Time:            entry.Updated,
+		})
+	}
+
+	return stageRuns
+}
+
+func revisionOf(cause gocd.BuildCause) string {
+	var revisions []string
+	for _, material := range cause.MaterialRevisions {
+		if len(material.Modifications) > 0 {
+			revisions = append(revisions, material.Material.Fingerprint+"@"+material.Modifications[0].Revision)
+		}
+	}
+	sort.Strings(revisions)
+
+	return strings.Join(revisions, ",")
+}
+
+// Analyze reports the build health of every pipeline and stage found in the runs.
+//
+// Only Passed and Failed runs count towards the pass rate. A recovery starts with the first Failed run and
+// ends with the next Passed run, so that Recovering runs in between extend the time to recovery. A red
+// streak counts consecutive Failed runs. A flake is a Failed run followed by a Passed run of the same
+// revision, which is either a rerun within the same pipeline run or a pipeline run building the same materials.
+// Pipelines are judged by the latest run of their stages in each pipeline run.
+func Analyze(runs []StageRun, options Options) BuildHealth {
+	runs = withinWindow(runs, options)
+	sort.SliceStable(runs, func(i, j int) bool {
+		if runs[i].PipelineCounter != runs[j].PipelineCounter {
+			return runs[i].PipelineCounter < runs[j].PipelineCounter
+		}
+		if runs[i].StageCounter != runs[j].StageCounter {
+			return runs[i].StageCounter < runs[j].StageCounter
+		}
+		return runs[i].Time.Before(runs[j].Time)
+	})
+
+	stageRuns := map[[2]string][]StageRun{}
+	var stageKeys [][2]string
+	for _, run := range runs {
+		key := [2]string{run.Pipeline, run.Stage}
+		if _, ok := stageRuns[key]; !ok {
+			stageKeys = append(stageKeys, key)
+		}
+		stageRuns[key] = append(stageRuns[key], run)
+	}
+	sort.Slice(stageKeys, func(i, j int) bool {
+		if stageKeys[i][0] != stageKeys[j][0] {
+			return stageKeys[i][0] < stageKeys[j][0]
+		}
+		return stageKeys[i][1] < stageKeys[j][1]
+	})
+
+	health := BuildHealth{Pipelines: []Report{}, Stages: []Report{}}
+	pipelineRuns := map[string][]StageRun{}
+	pipelineFlakes := map[string][]Flake{}
+	var pipelines []string
+	for _, key := range stageKeys {
+		report := analyzeRuns(stageRuns[key])
+		report.Pipeline, report.Stage = key[0], key[1]
+		report.Flakes = flakes(stageRuns[key])
+		health.Stages = append(health.Stages, report)
+
+		if _, ok := pipelineRuns[key[0]]; !ok {
+			pipelines = append(pipelines, key[0])
+		}
+		pipelineRuns[key[0]] = append(pipelineRuns[key[0]], latestStageRuns(stageRuns[key])...)
+		pipelineFlakes[key[0]] = append(pipelineFlakes[key[0]], report.Flakes...)
+	}
+
+	for _, pipeline := range pipelines {
+		report := analyzeRuns(combinePipelineRuns(pipelineRuns[pipeline]))
+		report.Pipeline = pipeline
+		report.Flakes = pipelineFlakes[pipeline]
+		health.Pipelines = append(health.Pipelines, report)
+	}
+
+	return health
+}
+
+func withinWindow(runs []StageRun, options Options) []StageRun {
+	if options.Window <= 0 {
+		return append([]StageRun{}, runs...)
+	}
+
+	now := options.Now
+	if now.IsZero() {
+		for _, run := range runs {
+			if run.Time.After(now) {
+				now = run.Time
+			}
+		}
+	}
+
+	start := now.Add(-options.Window)
+	var selected []StageRun
+	for _, run := range runs {
+		if !run.Time.Before(start) && !run.Time.After(now) {
+			selected = append(selected, run)
+		}
+	}
+
+	return selected
+}
+
+// analyzeRuns must be given the runs in the order they happened.
+func analyzeRuns(runs []StageRun) Report {
+	report := Report{}
+	var redSince *StageRun
+	var streak int
+	var recoveryTime time.Duration
+
+	for i, run := range runs {
+		switch {
+		case strings.EqualFold(run.Result, passed):
+			report.Passed++
+			streak = 0
+			if redSince != nil {
+				report.Recoveries++
+				recoveryTime += run.Time.Sub(redSince.Time)
+				redSince = nil
+			}
+		case strings.EqualFold(run.Result, failed):
+			report.Failed++
+			streak++
+			if streak > report.LongestRedStreak {
+				report.LongestRedStreak = streak
+			}
+			if redSince == nil {
+				redSince = &runs[i]
+			}
+		}
+	}
+
+	report.Runs = report.Passed + report.Failed
+	if report.Runs > 0 {
+		report.PassRate = float64(report.Passed) / float64(report.Runs)
+	}
+	if report.Recoveries > 0 {
+		report.MeanTimeToRecovery = recoveryTime / time.Duration(report.Recoveries)
+	}
+
+	return report
+}
+
+func flakes(runs []StageRun) []Flake {
+	var found []Flake
+	for i := 1; i < len(runs); i++ {
+		previous, current := runs[i-1], runs[i]
+		if !strings.EqualFold(previous.Result, failed) || !strings.EqualFold(current.Result, passed) {
+			continue
+		}
+		sameRevision := previous.PipelineCounter == current.PipelineCounter ||
+			(previous.Revision != "" && previous.Revision == current.Revision)
+		if sameRevision {
+			found = append(found, Flake{
+				Pipeline:        current.Pipeline,
+				Stage:           current.Stage,
+				PipelineCounter: current.PipelineCounter,
+				StageCounter:    current.StageCounter,
+				Revision:        current.Revision,
+			})
+		}
+	}
+
+	return found
+}
+
+// latestStageRuns keeps the last run of the stage in every pipeline run.
+func latestStageRuns(runs []StageRun) []StageRun {
+	var latest []StageRun
+	for i, run := range runs {
+		if i+1 < len(runs) && runs[i+1].PipelineCounter == run.PipelineCounter {
+			continue
+		}
+		latest = append(latest, run)
+	}
+
+	return latest
+}
+
+// combinePipelineRuns turns the stage runs of a pipeline into one run per pipeline run. A pipeline run
+// failed when any stage failed, passed when every stage passed, and is still going otherwise.
+func combinePipelineRuns(runs []StageRun) []StageRun {
+	byCounter := map[int]*StageRun{}
+	var counters []int
+	for _, run := range runs {
+		combined, ok := byCounter[run.PipelineCounter]
+		if !ok {
+			combined = &StageRun{Pipeline: run.Pipeline, PipelineCounter: run.PipelineCounter, Result: passed, Revision: run.Revision}
+			byCounter[run.PipelineCounter] = combined
+			counters = append(counters, run.PipelineCounter)
+		}
+		if run.Time.After(combined.Time) {
+			combined.Time = run.Time
+		}
+		switch {
+		case strings.EqualFold(run.Result, failed):
+			combined.Result = failed
+		case !strings.EqualFold(run.Result, passed) && !strings.EqualFold(combined.Result, failed):
+			combined.Result = run.Result
+		}
+	}
+	sort.Ints(counters)
+
+	var combined []StageRun
+	for _, counter := range counters {
+		combined = append(combined, *byCounter[counter])
+	}
+
+	return combined
+}
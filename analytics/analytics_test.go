@@ -0,0 +1,241 @@
+// analytics_test.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package analytics_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/chiku/gocd"
+	"github.com/chiku/gocd/analytics"
+)
+
+var start = time.Date(2017, time.July, 14, 2, 0, 0, 0, time.UTC)
+
+func stageRun(pipelineCounter int, stage string, stageCounter int, result string, minutes int, revision string) analytics.StageRun {
+	return analytics.StageRun{
+		Pipeline:        "Build",
+		PipelineCounter: pipelineCounter,
+		Stage:           stage,
+		StageCounter:    stageCounter,
+		Result:          result,
+		Revision:        revision,
+		Time:            start.Add(time.Duration(minutes) * time.Minute),
+	}
+}
+
+func TestAnalyzeStage(t *testing.T) {
+	runs := []analytics.StageRun{
+		stageRun(1, "Test", 1, "Passed", 0, "a"),
+		stageRun(2, "Test", 1, "Failed", 10, "b"),
+		stageRun(3, "Test", 1, "Failed", 20, "c"),
+		stageRun(4, "Test", 1, "Recovering", 25, "d"),
+		stageRun(4, "Test", 2, "Passed", 40, "d"),
+		stageRun(5, "Test", 1, "Failed", 50, "e"),
+		stageRun(6, "Test", 1, "Passed", 70, "f"),
+		stageRun(7, "Test", 1, "Cancelled", 80, "g"),
+	}
+
+	health := analytics.Analyze(runs, analytics.Options{})
+
+	if len(health.Stages) != 1 {
+		t.Fatalf("Expected a report for one stage, but was: %#v", health.Stages)
+	}
+	report := health.Stages[0]
+	if report.Pipeline != "Build" || report.Stage != "Test" || report.Runs != 6 || report.Passed != 3 || report.Failed != 3 {
+		t.Errorf("Expected proper run counts, but was: %#v", report)
+	}
+	if report.PassRate != 0.5 {
+		t.Errorf("Expected pass rate of 3 in 6, but was: %f", report.PassRate)
+	}
+	if report.Recoveries != 2 || report.MeanTimeToRecovery != 25*time.Minute {
+		t.Errorf("Expected 2 recoveries taking 25 minutes on average, but was: %d %s", report.Recoveries, report.MeanTimeToRecovery)
+	}
+	if report.LongestRedStreak != 2 {
+		t.Errorf("Expected longest red streak of 2, but was: %d", report.LongestRedStreak)
+	}
+	if report.IsFlaky() {
+		t.Errorf("Expected passes on new revisions not to be flaky, but was: %#v", report.Flakes)
+	}
+}
+
+func TestAnalyzeFindsFlakyStages(t *testing.T) {
+	runs := []analytics.StageRun{
+		stageRun(1, "Test", 1, "Failed", 0, "a"),
+		stageRun(1, "Test", 2, "Passed", 5, "a"),
+		stageRun(2, "Test", 1, "Failed", 10, "b"),
+		stageRun(3, "Test", 1, "Passed", 20, "b"),
+		stageRun(4, "Test", 1, "Failed", 30, "c"),
+		stageRun(5, "Test", 1, "Passed", 40, "d"),
+	}
+
+	health := analytics.Analyze(runs, analytics.Options{})
+
+	expected := []analytics.Flake{
+		{Pipeline: "Build", Stage: "Test", PipelineCounter: 1, StageCounter: 2, Revision: "a"},
+		{Pipeline: "Build", Stage: "Test", PipelineCounter: 3, StageCounter: 1, Revision: "b"},
+	}
+	if !reflect.DeepEqual(health.Stages[0].Flakes, expected) {
+		t.Errorf("Expected flakes on reruns of the same revision (%#v != %#v)", health.Stages[0].Flakes, expected)
+	}
+	if !reflect.DeepEqual(health.Pipelines[0].Flakes, expected) {
+		t.Errorf("Expected pipeline to carry the flakes of its stages (%#v != %#v)", health.Pipelines[0].Flakes, expected)
+	}
+}
+
+func TestAnalyzePipeline(t *testing.T) {
+	runs := []analytics.StageRun{
+		stageRun(1, "Compile", 1, "Passed", 0, "a"),
+		stageRun(1, "Test", 1, "Passed", 5, "a"),
+		stageRun(2, "Compile", 1, "Passed", 10, "b"),
+		stageRun(2, "Test", 1, "Failed", 15, "b"),
+		stageRun(2, "Test", 2, "Passed", 20, "b"),
+		stageRun(3, "Compile", 1, "Failed", 30, "c"),
+		stageRun(4, "Compile", 1, "Passed", 40, "d"),
+		stageRun(4, "Test", 1, "Unknown", 45, "d"),
+	}
+
+	health := analytics.Analyze(runs, analytics.Options{})
+
+	if len(health.Pipelines) != 1 || len(health.Stages) != 2 || health.Stages[0].Stage != "Compile" || health.Stages[1].Stage != "Test" {
+		t.Fatalf("Expected one pipeline with two stages sorted by name, but was: %#v", health)
+	}
+	report := health.Pipelines[0]
+	if report.Pipeline != "Build" || report.Stage != "" || report.Runs != 3 || report.Passed != 2 || report.Failed != 1 {
+		t.Errorf("Expected pipeline judged by the latest run of its stages, but was: %#v", report)
+	}
+	if report.Recoveries != 0 || report.LongestRedStreak != 1 {
+		t.Errorf("Expected an unrecovered failure, but was: %#v", report)
+	}
+}
+
+func TestAnalyzeWithinWindow(t *testing.T) {
+	runs := []analytics.StageRun{
+		stageRun(1, "Test", 1, "Failed", 0, "a"),
+		stageRun(2, "Test", 1, "Passed", 30, "b"),
+		stageRun(3, "Test", 1, "Passed", 60, "c"),
+	}
+
+	health := analytics.Analyze(runs, analytics.Options{Window: 45 * time.Minute})
+	if report := health.Stages[0]; report.Runs != 2 || report.PassRate != 1 {
+		t.Errorf("Expected runs in the last 45 minutes before the latest run, but was: %#v", report)
+	}
+
+	health = analytics.Analyze(runs, analytics.Options{Window: 45 * time.Minute, Now: start.Add(40 * time.Minute)})
+	if report := health.Stages[0]; report.Runs != 2 || report.Recoveries != 1 || report.MeanTimeToRecovery != 30*time.Minute {
+		t.Errorf("Expected runs in the 45 minutes before the given time, but was: %#v", report)
+	}
+}
+
+func TestAnalyzeWithoutRuns(t *testing.T) {
+	data, err := json.Marshal(analytics.Analyze(nil, analytics.Options{}))
+
+	if err != nil || string(data) != `{"pipelines":[],"stages":[]}` {
+		t.Errorf("Expected empty reports, but was: %s (%v)", data, err)
+	}
+}
+
+func TestReportJSON(t *testing.T) {
+	report := analytics.Report{Pipeline: "Build", Stage: "Test", Runs: 2, Passed: 1, Failed: 1, PassRate: 0.5, Recoveries: 1, MeanTimeToRecovery: 90 * time.Second, LongestRedStreak: 1}
+
+	data, err := json.Marshal(report)
+
+	expected := `{"pipeline":"Build","stage":"Test","runs":2,"passed":1,"failed":1,"pass_rate":0.5,"recoveries":1,"longest_red_streak":1,"mean_time_to_recovery_seconds":90}`
+	if err != nil || string(data) != expected {
+		t.Errorf("Expected proper JSON (%s != %s), error: %v", data, expected, err)
+	}
+}
+
+func TestFromPipelineHistory(t *testing.T) {
+	body := `{
+		"pipelines": [{
+			"name": "Build",
+			"counter": 7,
+			"label": "7",
+			"build_cause": {
+				"material_revisions": [
+					{ "material": { "fingerprint": "f2" }, "modifications": [{ "revision": "r2" }] },
+					{ "material": { "fingerprint": "f1" }, "modifications": [{ "revision": "r1" }] }
+				]
+			},
+			"stages": [
+				{ "name": "Test", "counter": "2", "result": "Passed", "scheduled": true, "jobs": [{ "scheduled_date": 1500000060000 }, { "scheduled_date": 1500000000000 }] },
+				{ "name": "Deploy", "counter": "1", "scheduled": false, "jobs": [] }
+			]
+		}],
+		"pagination": { "offset": 0, "total": 1, "page_size": 10 }
+	}`
+	var history gocd.PipelineHistory
+	if err := json.Unmarshal([]byte(body), &history); err != nil {
+		t.Fatalf("Expected no error unmarshalling history: %s", err)
+	}
+
+	runs := analytics.FromPipelineHistory(history.Pipelines)
+
+	expected := []analytics.StageRun{{
+		Pipeline:        "Build",
+		PipelineCounter: 7,
+		Stage:           "Test",
+		StageCounter:    2,
+		Result:          "Passed",
+		Revision:        "f1@r1,f2@r2",
+		Time:            time.Unix(1500000000, 0).UTC(),
+	}}
+	if !reflect.DeepEqual(runs, expected) {
+		t.Errorf("Expected scheduled stage runs (%#v != %#v)", runs, expected)
+	}
+}
+
+func TestFromStageHistoryFindsRerunFlakes(t *testing.T) {
+	body := `{
+		"pagination": { "offset": 0, "total": 4, "page_size": 10 },
+		"stages": [
+			{ "name": "Test", "counter": "1", "result": "Passed", "scheduled": true, "pipeline_name": "Build", "pipeline_counter": 8, "jobs": [{ "scheduled_date": 1500001200000 }] },
+			{ "name": "Test", "counter": "2", "result": "Passed", "scheduled": true, "pipeline_name": "Build", "pipeline_counter": 7, "jobs": [{ "scheduled_date": 1500000600000 }] },
+			{ "name": "Test", "counter": "1", "result": "Failed", "scheduled": true, "pipeline_name": "Build", "pipeline_counter": 7, "jobs": [{ "scheduled_date": 1500000000000 }] },
+			{ "name": "Test", "counter": "1", "scheduled": false, "pipeline_name": "Build", "pipeline_counter": 6, "jobs": [] }
+		]
+	}`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	history, err := gocd.NewClient().StageHistory(ts.URL, "Build", "Test", 0)
+	if err != nil {
+		t.Fatalf("Expected no error fetching stage history: %s", err)
+	}
+
+	runs := analytics.FromStageHistory(history.Stages)
+	if len(runs) != 3 || runs[1].PipelineCounter != 7 || runs[1].StageCounter != 2 || !runs[2].Time.Equal(time.Unix(1500000000, 0)) {
+		t.Fatalf("Expected scheduled stage runs, but was: %#v", runs)
+	}
+
+	health := analytics.Analyze(runs, analytics.Options{})
+	expected := []analytics.Flake{{Pipeline: "Build", Stage: "Test", PipelineCounter: 7, StageCounter: 2}}
+	if !reflect.DeepEqual(health.Stages[0].Flakes, expected) {
+		t.Errorf("Expected the rerun to be a flake (%#v != %#v)", health.Stages[0].Flakes, expected)
+	}
+	if pipeline := health.Pipelines[0]; pipeline.Runs != 2 || pipeline.Passed != 2 || !pipeline.IsFlaky() {
+		t.Errorf("Expected the pipeline to be judged by the rerun, and flaky, but was: %#v", pipeline)
+	}
+}
+
+func TestFromStageFeed(t *testing.T) {
+	entries := []gocd.StageFeedEntry{{Pipeline: "Build", PipelineCounter: 3, Stage: "Test", StageCounter: 1, Result: "Failed", Updated: start}}
+
+	runs := analytics.FromStageFeed(entries)
+
+	expected := []analytics.StageRun{{Pipeline: "Build", PipelineCounter: 3, Stage: "Test", StageCounter: 1, Result: "Failed", Time: start}}
+	if !reflect.DeepEqual(runs, expected) {
+		t.Errorf("Expected stage runs from the feed (%#v != %#v)", runs, expected)
+	}
+}
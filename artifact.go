@@ -0,0 +1,181 @@
+// artifact.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const checksumPath = "cruise-output/md5.checksum"
+
+// Artifact is a file or a folder published by a job.
+type Artifact struct {
+	Name  string     `json:"name"`
+	URL   string     `json:"url"`
+	Type  string     `json:"type"`
+	Files []Artifact `json:"files"`
+}
+
+// NotReadyError is returned when Gocd accepted a download but has not yet prepared it,
+// as when it is still zipping an artifact folder. Try again later.
+type NotReadyError struct {
+	Path    string
+	Message string
+}
+
+func (err *NotReadyError) Error() string {
+	return fmt.Sprintf("error downloading artifact %s: it is not ready yet: %s", err.Path, err.Message)
+}
+
+// IsFolder tells if the artifact contains other artifacts.
+func (artifact Artifact) IsFolder() bool {
+	return artifact.Type == "folder"
+}
+
+// Artifacts lists the artifact tree of a job.
+func (c Client) Artifacts(server string, job JobLocator) ([]Artifact, error) {
+	request, err := http.NewRequest("GET", strings.TrimRight(server, "/")+job.filesPath()+".json", nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating Gocd request: %s", err)
+	}
+
+	var artifacts []Artifact
+	err = c.performJSON(request, &artifacts)
+	if err != nil {
+		return nil, err
+	}
+
+	return artifacts, nil
+}
+
+// DownloadArtifact streams a single artifact file into writer and returns the count of bytes written.
+func (c Client) DownloadArtifact(server string, job JobLocator, path string, writer io.Writer) (int64, error) {
+	return c.download(server, job, escapeArtifactPath(path), writer)
+}
+
+// DownloadArtifactDirectory streams an artifact folder, zipped by Gocd, into writer.
+// Gocd zips the folder on the first request, returning a *NotReadyError until the zip file is created.
+func (c Client) DownloadArtifactDirectory(server string, job JobLocator, path string, writer io.Writer) (int64, error) {
+	return c.download(server, job, escapeArtifactPath(path)+".zip", writer)
+}
+
+// DownloadVerifiedArtifact streams an artifact file into writer and matches its MD5 against the
+// checksum Gocd published for the job. Files without a published checksum are not verified.
+// On a mismatch the content is already written, so the caller should discard it.
+func (c Client) DownloadVerifiedArtifact(server string, job JobLocator, path string, writer io.Writer) (int64, error) {
+	checksums, err := c.ArtifactChecksums(server, job)
+	if err != nil {
+		return 0, err
+	}
+
+	expected, ok := checksums[strings.TrimLeft(path, "/")]
+	if !ok {
+		return c.DownloadArtifact(server, job, path, writer)
+	}
+
+	hash := md5.New()
+	written, err := c.DownloadArtifact(server, job, path, io.MultiWriter(writer, hash))
+	if err != nil {
+		return written, err
+	}
+
+	actual := hex.EncodeToString(hash.Sum(nil))
+	if !strings.EqualFold(actual, expected) {
+		return written, fmt.Errorf("error verifying artifact %s: expected MD5 %s, but was %s", path, expected, actual)
+	}
+
+	return written, nil
+}
+
+// ArtifactChecksums returns the MD5 checksums Gocd recorded for the artifacts of a job, keyed by path.
+// It is empty when the job has no checksum file.
+func (c Client) ArtifactChecksums(server string, job JobLocator) (map[string]string, error) {
+	request, err := http.NewRequest("GET", strings.TrimRight(server, "/")+job.filesPath()+"/"+checksumPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating Gocd request: %s", err)
+	}
+
+	response, err := c.receive(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode == http.StatusNotFound {
+		response.Body.Close()
+		return map[string]string{}, nil
+	}
+
+	body, err := readHTTPResponse(response)
+	if err != nil {
+		return nil, err
+	}
+	err = checkNotHTML(response, "checksums")
+	if err != nil {
+		return nil, err
+	}
+
+	return parseChecksums(body), nil
+}
+
+func (c Client) download(server string, job JobLocator, path string, writer io.Writer) (int64, error) {
+	request, err := http.NewRequest("GET", strings.TrimRight(server, "/")+job.filesPath()+"/"+path, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error creating Gocd request: %s", err)
+	}
+
+	response, err := c.stream(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusAccepted {
+		return 0, &NotReadyError{Path: path, Message: strings.TrimSpace(readErrorBody(response))}
+	}
+
+	written, err := io.Copy(writer, response.Body)
+	if err != nil {
+		return written, fmt.Errorf("error downloading artifact %s: %s", path, err)
+	}
+
+	return written, nil
+}
+
+func parseChecksums(body []byte) map[string]string {
+	checksums := map[string]string{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		separator := strings.LastIndex(line, "=")
+		if separator < 0 {
+			continue
+		}
+		checksums[strings.TrimSpace(line[:separator])] = strings.TrimSpace(line[separator+1:])
+	}
+
+	return checksums
+}
+
+func escapeArtifactPath(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,193 @@
+// artifact_test.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd_test
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chiku/gocd"
+)
+
+const artifactJobPath = "/go/files/Pipeline/3/Stage/1/Job"
+
+func fakeArtifactServer(checksums string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case artifactJobPath + ".json":
+			w.Write([]byte(`[{
+				"name": "reports",
+				"url": "http://gocd/go/files/Pipeline/3/Stage/1/Job/reports",
+				"type": "folder",
+				"files": [{
+					"name": "junit.xml",
+					"url": "http://gocd/go/files/Pipeline/3/Stage/1/Job/reports/junit.xml",
+					"type": "file"
+				}]
+			}]`))
+		case artifactJobPath + "/reports/junit.xml":
+			w.Write([]byte("<testsuite/>"))
+		case artifactJobPath + "/reports.zip":
+			w.Write([]byte("PK-zipped"))
+		case artifactJobPath + "/logs.zip":
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte("Creating zip file. Please try again later."))
+		case artifactJobPath + "/cruise-output/md5.checksum":
+			if checksums == "" {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte(checksums))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestClientArtifacts(t *testing.T) {
+	ts := fakeArtifactServer("")
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	artifacts, err := client.Artifacts(ts.URL, consoleJob)
+
+	if err != nil {
+		t.Fatalf("Expected no error listing artifacts: %s", err)
+	}
+
+	expected := []gocd.Artifact{{
+		Name: "reports",
+		URL:  "http://gocd/go/files/Pipeline/3/Stage/1/Job/reports",
+		Type: "folder",
+		Files: []gocd.Artifact{{
+			Name: "junit.xml",
+			URL:  "http://gocd/go/files/Pipeline/3/Stage/1/Job/reports/junit.xml",
+			Type: "file",
+		}},
+	}}
+	if !reflect.DeepEqual(artifacts, expected) {
+		t.Errorf("Expected artifact tree (%#v != %#v)", artifacts, expected)
+	}
+	if !artifacts[0].IsFolder() || artifacts[0].Files[0].IsFolder() {
+		t.Errorf("Expected folders to be recognized: %#v", artifacts)
+	}
+}
+
+func TestClientDownloadArtifact(t *testing.T) {
+	ts := fakeArtifactServer("")
+	defer ts.Close()
+
+	var buffer bytes.Buffer
+	client := gocd.NewClient()
+	written, err := client.DownloadArtifact(ts.URL, consoleJob, "reports/junit.xml", &buffer)
+
+	if err != nil {
+		t.Fatalf("Expected no error downloading artifact: %s", err)
+	}
+	if buffer.String() != "<testsuite/>" || written != 12 {
+		t.Errorf("Expected artifact content, but was: %q (%d bytes)", buffer.String(), written)
+	}
+}
+
+func TestClientDownloadArtifactDirectory(t *testing.T) {
+	ts := fakeArtifactServer("")
+	defer ts.Close()
+
+	var buffer bytes.Buffer
+	client := gocd.NewClient()
+	_, err := client.DownloadArtifactDirectory(ts.URL, consoleJob, "reports/", &buffer)
+
+	if err != nil {
+		t.Fatalf("Expected no error downloading artifact directory: %s", err)
+	}
+	if buffer.String() != "PK-zipped" {
+		t.Errorf("Expected zipped directory, but was: %q", buffer.String())
+	}
+}
+
+func TestClientDownloadArtifactDirectoryNotZippedYet(t *testing.T) {
+	ts := fakeArtifactServer("")
+	defer ts.Close()
+
+	var buffer bytes.Buffer
+	client := gocd.NewClient()
+	written, err := client.DownloadArtifactDirectory(ts.URL, consoleJob, "logs", &buffer)
+
+	var notReady *gocd.NotReadyError
+	if !errors.As(err, &notReady) {
+		t.Fatalf("Expected not ready error, but was: %v", err)
+	}
+	if notReady.Path != "logs.zip" || notReady.Message != "Creating zip file. Please try again later." {
+		t.Errorf("Expected proper not ready error, but was: %#v", notReady)
+	}
+	if written != 0 || buffer.Len() != 0 {
+		t.Errorf("Expected nothing to be written, but was: %q", buffer.String())
+	}
+}
+
+func TestClientDownloadMissingArtifact(t *testing.T) {
+	ts := fakeArtifactServer("")
+	defer ts.Close()
+
+	var buffer bytes.Buffer
+	client := gocd.NewClient()
+	_, err := client.DownloadArtifact(ts.URL, consoleJob, "missing.txt", &buffer)
+
+	if err == nil || !strings.Contains(err.Error(), "the HTTP status code was 404") {
+		t.Errorf("Expected proper error message but was: %v", err)
+	}
+}
+
+func TestClientDownloadVerifiedArtifact(t *testing.T) {
+	ts := fakeArtifactServer("# md5\nreports/junit.xml=D5B5FE72415EAD3CBBF6F3F7B36C54BF\n")
+	defer ts.Close()
+
+	var buffer bytes.Buffer
+	client := gocd.NewClient()
+	_, err := client.DownloadVerifiedArtifact(ts.URL, consoleJob, "reports/junit.xml", &buffer)
+
+	if err != nil {
+		t.Fatalf("Expected no error downloading verified artifact: %s", err)
+	}
+	if buffer.String() != "<testsuite/>" {
+		t.Errorf("Expected artifact content, but was: %q", buffer.String())
+	}
+}
+
+func TestClientDownloadVerifiedArtifactWithChecksumMismatch(t *testing.T) {
+	ts := fakeArtifactServer("reports/junit.xml=00000000000000000000000000000000\n")
+	defer ts.Close()
+
+	var buffer bytes.Buffer
+	client := gocd.NewClient()
+	_, err := client.DownloadVerifiedArtifact(ts.URL, consoleJob, "reports/junit.xml", &buffer)
+
+	if err == nil || !strings.Contains(err.Error(), "error verifying artifact reports/junit.xml: expected MD5 00000000000000000000000000000000") {
+		t.Errorf("Expected checksum mismatch error but was: %v", err)
+	}
+}
+
+func TestClientDownloadVerifiedArtifactWithoutChecksums(t *testing.T) {
+	ts := fakeArtifactServer("")
+	defer ts.Close()
+
+	var buffer bytes.Buffer
+	client := gocd.NewClient()
+	_, err := client.DownloadVerifiedArtifact(ts.URL, consoleJob, "reports/junit.xml", &buffer)
+
+	if err != nil {
+		t.Fatalf("Expected no error downloading artifact without checksums: %s", err)
+	}
+	if buffer.String() != "<testsuite/>" {
+		t.Errorf("Expected artifact content, but was: %q", buffer.String())
+	}
+}
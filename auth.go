@@ -0,0 +1,73 @@
+// auth.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const loginPath = "/go/auth/login"
+
+// AuthError tells that Gocd did not accept the credentials of the client, or that none were given.
+// URL is where the request ended up after redirects, such as the login page.
+type AuthError struct {
+	StatusCode int
+	URL        string
+	Reason     string
+}
+
+func (err *AuthError) Error() string {
+	return fmt.Sprintf("error authenticating with Gocd at %s: %s", err.URL, err.Reason)
+}
+
+// checkAuthentication spots the ways Gocd turns down requests without proper credentials: a 401 or 403,
+// or a redirect to the login page.
+func checkAuthentication(response *http.Response) error {
+	if redirectedToLogin(response) {
+		return &AuthError{StatusCode: response.StatusCode, URL: finalURL(response), Reason: "the request was redirected to the login page"}
+	}
+
+	if response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden {
+		reason := fmt.Sprintf("the HTTP status code was %d, body: %s", response.StatusCode, readErrorBody(response))
+		return &AuthError{StatusCode: response.StatusCode, URL: finalURL(response), Reason: reason}
+	}
+
+	return nil
+}
+
+// redirectedToLogin tells if the client followed a redirect that ended on the login page.
+// Paths that merely contain the login path, such as artifacts stored under auth/login, are not redirects.
+func redirectedToLogin(response *http.Response) bool {
+	if response.Request == nil || response.Request.Response == nil {
+		return false
+	}
+
+	return strings.HasSuffix(strings.TrimRight(response.Request.URL.Path, "/"), loginPath)
+}
+
+// checkNotHTML rejects the HTML pages served by Gocd in place of the format expected, which are login pages
+// when the request was redirected by a proxy or single sign-on in front of Gocd.
+func checkNotHTML(response *http.Response, expected string) error {
+	mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	if mediaType == "text/html" || mediaType == "application/xhtml+xml" {
+		reason := fmt.Sprintf("expected %s but got an HTML page (%s), probably a login page", expected, mediaType)
+		return &AuthError{StatusCode: response.StatusCode, URL: finalURL(response), Reason: reason}
+	}
+
+	return nil
+}
+
+func finalURL(response *http.Response) string {
+	if response.Request == nil {
+		return ""
+	}
+
+	return response.Request.URL.Redacted()
+}
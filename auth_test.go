@@ -0,0 +1,145 @@
+// auth_test.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd_test
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chiku/gocd"
+)
+
+func fakeLoginServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/go/auth/login":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(`<html><body><form action="/go/auth/security_check"></form></body></html>`))
+		case "/go/api/agents":
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{ "message": "You are not authenticated!" }`))
+		default:
+			http.Redirect(w, r, "/go/auth/login", http.StatusFound)
+		}
+	}))
+}
+
+func expectAuthError(t *testing.T, err error, statusCode int, url string, reason string) {
+	var authErr *gocd.AuthError
+	if !errors.As(err, &authErr) {
+		t.Fatalf("Expected authentication error, but was: %v", err)
+	}
+	if authErr.StatusCode != statusCode || authErr.URL != url || authErr.Reason != reason {
+		t.Errorf("Expected proper authentication error, but was: %#v", authErr)
+	}
+}
+
+func TestClientFetchRedirectedToLogin(t *testing.T) {
+	ts := fakeLoginServer()
+	defer ts.Close()
+
+	dashboard, err := gocd.NewClient().Fetch(ts.URL + "/go/dashboard.json")
+
+	expectAuthError(t, err, http.StatusOK, ts.URL+"/go/auth/login", "the request was redirected to the login page")
+	if err.Error() != "error authenticating with Gocd at "+ts.URL+"/go/auth/login: the request was redirected to the login page" {
+		t.Errorf("Expected proper error message, but was: %s", err)
+	}
+	if dashboard != nil {
+		t.Errorf("Expected no dashboard, but was: %#v", dashboard)
+	}
+}
+
+func TestClientUnauthorized(t *testing.T) {
+	ts := fakeLoginServer()
+	defer ts.Close()
+
+	_, err := gocd.NewClient().Agents(ts.URL)
+
+	expectAuthError(t, err, http.StatusUnauthorized, ts.URL+"/go/api/agents", `the HTTP status code was 401, body: { "message": "You are not authenticated!" }`)
+}
+
+func TestClientDownloadRedirectedToLogin(t *testing.T) {
+	ts := fakeLoginServer()
+	defer ts.Close()
+
+	var out bytes.Buffer
+	job := gocd.JobLocator{StageLocator: gocd.StageLocator{PipelineName: "Build", PipelineCounter: 1, StageName: "Test", StageCounter: 1}, JobName: "Unit"}
+	_, err := gocd.NewClient().DownloadArtifact(ts.URL, job, "report.html", &out)
+
+	expectAuthError(t, err, http.StatusOK, ts.URL+"/go/auth/login", "the request was redirected to the login page")
+	if out.Len() != 0 {
+		t.Errorf("Expected login page not to be downloaded, but was: %s", out.String())
+	}
+}
+
+func TestClientServesHTMLArtifacts(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html></html>`))
+	}))
+	defer ts.Close()
+
+	var out bytes.Buffer
+	job := gocd.JobLocator{StageLocator: gocd.StageLocator{PipelineName: "Build", PipelineCounter: 1, StageName: "Test", StageCounter: 1}, JobName: "Unit"}
+	_, err := gocd.NewClient().DownloadArtifact(ts.URL, job, "report.html", &out)
+
+	if err != nil || out.String() != `<html></html>` {
+		t.Errorf("Expected HTML artifact to be downloaded, but was: %s (%v)", out.String(), err)
+	}
+}
+
+func TestClientDownloadArtifactUnderLoginPath(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`login page of the site`))
+	}))
+	defer ts.Close()
+
+	var out bytes.Buffer
+	job := gocd.JobLocator{StageLocator: gocd.StageLocator{PipelineName: "Build", PipelineCounter: 1, StageName: "Test", StageCounter: 1}, JobName: "Unit"}
+	_, err := gocd.NewClient().DownloadArtifact(ts.URL, job, "site/go/auth/login", &out)
+
+	if err != nil || out.String() != `login page of the site` {
+		t.Errorf("Expected artifact under a login path to be downloaded, but was: %s (%v)", out.String(), err)
+	}
+}
+
+func TestClientStageFeedRedirectedToLogin(t *testing.T) {
+	ts := fakeLoginServer()
+	defer ts.Close()
+
+	_, err := gocd.NewClient().StageFeed(ts.URL, "Build")
+
+	expectAuthError(t, err, http.StatusOK, ts.URL+"/go/auth/login", "the request was redirected to the login page")
+}
+
+func TestClientStageFeedServedHTML(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html></html>`))
+	}))
+	defer ts.Close()
+
+	_, err := gocd.NewClient().StageFeed(ts.URL, "Build")
+
+	expectAuthError(t, err, http.StatusOK, ts.URL+"/go/api/pipelines/Build/stages.xml", "expected an Atom feed but got an HTML page (text/html), probably a login page")
+}
+
+func TestClientArtifactChecksumsServedHTML(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html></html>`))
+	}))
+	defer ts.Close()
+
+	job := gocd.JobLocator{StageLocator: gocd.StageLocator{PipelineName: "Build", PipelineCounter: 1, StageName: "Test", StageCounter: 1}, JobName: "Unit"}
+	_, err := gocd.NewClient().ArtifactChecksums(ts.URL, job)
+
+	expectAuthError(t, err, http.StatusOK, ts.URL+"/go/files/Build/1/Test/1/Unit/cruise-output/md5.checksum", "expected checksums but got an HTML page (text/html), probably a login page")
+}
@@ -7,21 +7,63 @@
 package gocd
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
 const (
-	maxRetries = 3
+	maxRetries       = 3
+	maxErrorBodySize = 512
 )
 
+type operationResponse struct {
+	Message string `json:"message"`
+}
 type Client struct {
-	client *http.Client
+	client      *http.Client
+	version     *atomic.Value
+	observer    Observer
+	maxBodySize int64
+	transport   []func(*http.Transport)
+	wrappers    []func(http.RoundTripper) http.RoundTripper
+	err         error
+}
+
+// DashboardFetcher fetches the dashboard from Gocd. Client satisfies it; code depending on it
+// can be tested against a fake.
+type DashboardFetcher interface {
+	Fetch(url string) (Dashboard, error)
+}
+
+var _ DashboardFetcher = Client{}
+
+// Option configures a client created by NewClient.
+type Option func(*Client)
+
+// WithMaxBodySize limits the size of the responses read from Gocd, other than artifact downloads and
+// console logs. Responses going past it fail instead of being read whole. Responses are not limited by
+// default, and a size of 0 or less lifts the limit.
+func WithMaxBodySize(size int64) Option {
+	return func(c *Client) {
+		c.maxBodySize = size
+	}
 }
 
-func NewClient() *Client {
-	return &Client{client: &http.Client{}}
+func NewClient(options ...Option) *Client {
+	c := &Client{client: &http.Client{}, version: &atomic.Value{}}
+	for _, option := range options {
+		option(c)
+	}
+	c.err = c.applyTransport()
+
+	return c
 }
 
 func (c Client) Fetch(url string) (Dashboard, error) {
@@ -30,24 +72,164 @@ func (c Client) Fetch(url string) (Dashboard, error) {
 		return nil, fmt.Errorf("error creating Gocd request: %s", err)
 	}
 
-	response, err := fetchGocdDashboard(c.client, request)
+	return c.fetch(request)
+}
+
+func (c Client) fetch(request *http.Request) (Dashboard, error) {
+	response, err := c.receive(request)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseHTTPResponse(c.observe(), response)
+}
+
+func newGocdRequest(method string, url string, accept string, payload []byte) (*http.Request, error) {
+	request, err := http.NewRequest(method, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("error creating Gocd request: %s", err)
+	}
+
+	request.Header.Set("Accept", accept)
+	if payload != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	return request, nil
+}
+
+// receive fetches a response from Gocd, with its body limited to the largest size allowed.
+func (c Client) receive(request *http.Request) (*http.Response, error) {
+	response, err := c.send(request)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching data from Gocd: %s", err)
 	}
 
-	groups, err := parseHTTPResponse(response)
+	if c.maxBodySize > 0 {
+		response.Body = &limitedBody{ReadCloser: response.Body, limit: c.maxBodySize, remaining: c.maxBodySize}
+	}
+	return response, nil
+}
+
+func (c Client) perform(request *http.Request) ([]byte, error) {
+	response, err := c.receive(request)
 	if err != nil {
 		return nil, err
 	}
 
-	dashboard := groups.ToDashboard()
-	return dashboard, nil
+	return readHTTPResponse(response)
+}
+
+// receiveJSON is perform for requests answered with JSON.
+func (c Client) receiveJSON(request *http.Request) ([]byte, error) {
+	return c.receiveNotHTML(request, "JSON")
+}
+
+// receiveNotHTML is perform for requests answered with anything but HTML, which Gocd serves for login pages.
+func (c Client) receiveNotHTML(request *http.Request, expected string) ([]byte, error) {
+	response, err := c.receive(request)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := readHTTPResponse(response)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkNotHTML(response, expected)
+	if err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
+
+func (c Client) performJSON(request *http.Request, target interface{}) error {
+	body, err := c.receiveJSON(request)
+	if err != nil {
+		return err
+	}
+
+	started := time.Now()
+	err = json.Unmarshal(body, target)
+	c.observe().OnDecode(request, "json", time.Since(started), err)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling Gocd JSON: %s", err)
+	}
+
+	return nil
+}
+
+func (c Client) operation(server string, path string, accept string, payload []byte) (string, error) {
+	request, err := newGocdRequest("POST", strings.TrimRight(server, "/")+path, accept, payload)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("X-GoCD-Confirm", "true")
+
+	var response operationResponse
+	err = c.performJSON(request, &response)
+	if err != nil {
+		return "", err
+	}
+
+	return response.Message, nil
+}
+
+func (c Client) stream(request *http.Request) (*http.Response, error) {
+	response, err := c.send(request)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching data from Gocd: %s", err)
+	}
+
+	return streamHTTPResponse(response)
+}
+
+// streamHTTPResponse hands over the body of a successful response unread.
+func streamHTTPResponse(response *http.Response) (*http.Response, error) {
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		_, err := readHTTPResponse(response)
+		return nil, err
+	}
+	err := checkAuthentication(response)
+	if err != nil {
+		response.Body.Close()
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// send makes a request to Gocd, unless the options of the client were inconsistent.
+func (c Client) send(request *http.Request) (*http.Response, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	return fetchGocdDashboard(c.client, c.observe(), request)
 }
 
-func fetchGocdDashboard(client *http.Client, request *http.Request) (response *http.Response, err error) {
+func fetchGocdDashboard(client *http.Client, observer Observer, request *http.Request) (response *http.Response, err error) {
 	retries := 0
+	started := time.Now()
+	// The request takes the context from the observer in place, as observers tell requests apart by pointer.
+	if ctx := observer.OnRequestStart(request); ctx != nil && ctx != request.Context() {
+		*request = *request.WithContext(ctx)
+	}
+	defer func() {
+		observer.OnResponse(request, response, time.Since(started), err)
+	}()
+
+	attempts := maxRetries
+	if !isRetryable(request) {
+		attempts = 1
+	}
 
-	for response == nil && retries < maxRetries {
+	for response == nil && retries < attempts {
+		if retries > 0 {
+			observer.OnRetry(request, retries+1, err)
+		}
 		response, err = client.Do(request)
 		if err != nil {
 			err = fmt.Errorf("%s (after %d retries)", err, retries+1)
@@ -58,24 +240,108 @@ func fetchGocdDashboard(client *http.Client, request *http.Request) (response *h
 	return
 }
 
-func parseHTTPResponse(response *http.Response) (PipelineGroups, error) {
+// isRetryable tells if a request is safe to send again after a failure. Gocd may have acted on an
+// operation whose response was lost, so only reads are retried.
+func isRetryable(request *http.Request) bool {
+	return request.Method == "GET" || request.Method == "HEAD" || request.Method == ""
+}
+
+// parseHTTPResponse streams legacy dashboards, but reads HAL dashboards whole as their pipelines
+// are listed apart from the groups that order them.
+func parseHTTPResponse(observer Observer, response *http.Response) (Dashboard, error) {
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		_, err := readHTTPResponse(response)
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	err := checkAuthentication(response)
+	if err == nil {
+		err = checkNotHTML(response, "JSON")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	started := time.Now()
+	if isHALResponse(response) {
+		body, err := ioutil.ReadAll(response.Body)
+		if err != nil {
+			return nil, fmt.Errorf("error reading response: %s, the HTTP status code was %d", err, response.StatusCode)
+		}
+
+		groups, err := NewPipelineGroupsFromHAL(body)
+		observer.OnDecode(response.Request, "hal", time.Since(started), err)
+		if err != nil {
+			return nil, err
+		}
+		return groups.ToDashboard(), nil
+	}
+
+	dashboard, err := NewDashboardFromReader(response.Body)
+	observer.OnDecode(response.Request, "legacy", time.Since(started), err)
+	if err != nil {
+		return nil, err
+	}
+
+	return dashboard, nil
+}
+
+func isHALResponse(response *http.Response) bool {
+	contentType := response.Header.Get("Content-Type")
+	return strings.HasPrefix(contentType, "application/vnd.go.cd.") || strings.HasPrefix(contentType, "application/hal+json")
+}
+
+func readHTTPResponse(response *http.Response) ([]byte, error) {
 	if response != nil {
 		defer response.Body.Close()
 	}
 
+	err := checkAuthentication(response)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("error fetching response from Gocd: the HTTP status code was %d, body: %s", response.StatusCode, readErrorBody(response))
+	}
+
 	body, err := ioutil.ReadAll(response.Body)
 	if err != nil {
 		return nil, fmt.Errorf("error reading response: %s, the HTTP status code was %d", err, response.StatusCode)
 	}
 
-	if response.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("error fetching response from Gocd: the HTTP status code was %d, body: %s", response.StatusCode, body)
+	return body, nil
+}
+
+// readErrorBody reads enough of an error response to explain the error, and no more.
+func readErrorBody(response *http.Response) string {
+	body, _ := ioutil.ReadAll(io.LimitReader(response.Body, maxErrorBodySize+1))
+	if len(body) > maxErrorBodySize {
+		return string(body[:maxErrorBodySize]) + "... (truncated)"
 	}
 
-	groups, err := NewPipelineGroups(body)
-	if err != nil {
-		return nil, err
+	return string(body)
+}
+
+type limitedBody struct {
+	io.ReadCloser
+	limit     int64
+	remaining int64
+}
+
+func (body *limitedBody) Read(p []byte) (int, error) {
+	if int64(len(p)) > body.remaining+1 {
+		p = p[:body.remaining+1]
+	}
+
+	n, err := body.ReadCloser.Read(p)
+	if int64(n) > body.remaining {
+		n = int(body.remaining)
+		body.remaining = 0
+		return n, fmt.Errorf("response body larger than %d bytes", body.limit)
 	}
+	body.remaining -= int64(n)
 
-	return groups, nil
+	return n, err
 }
@@ -7,9 +7,11 @@
 package gocd_test
 
 import (
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/chiku/gocd"
@@ -86,7 +88,7 @@ func TestClientFetchWhenServerResponseNot200(t *testing.T) {
 	if err == nil {
 		t.Fatalf("Expected error fetching invalid response: %s", err)
 	}
-	if err.Error() != "error fetching response from Gocd: the HTTP status code was 403, body: Forbidden!" {
+	if err.Error() != "error authenticating with Gocd at "+ts.URL+": the HTTP status code was 403, body: Forbidden!" {
 		t.Errorf("Expected proper error message but was: %s", err.Error())
 	}
 
@@ -137,6 +139,49 @@ func TestClientFetchWhenServerDoesNotRespondAfterRetries(t *testing.T) {
 	}
 }
 
+func droppingServer(attempts *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(attempts, 1)
+		ioutil.ReadAll(r.Body)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+}
+
+func TestClientDoesNotRetryOperationsWhenConnectionDrops(t *testing.T) {
+	var attempts int32
+	ts := droppingServer(&attempts)
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	_, err := client.RerunStage(ts.URL, gocd.StageLocator{PipelineName: "Pipeline", PipelineCounter: 1, StageName: "Stage"})
+
+	if err == nil {
+		t.Fatalf("Expected error when the connection drops")
+	}
+	if reached := atomic.LoadInt32(&attempts); reached != 1 {
+		t.Errorf("Expected the operation to reach Gocd once, but was: %d", reached)
+	}
+}
+
+func TestClientRetriesReadsWhenConnectionDrops(t *testing.T) {
+	var attempts int32
+	ts := droppingServer(&attempts)
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	_, err := client.Fetch(ts.URL)
+
+	if err == nil || !strings.Contains(err.Error(), "(after 3 retries)") {
+		t.Fatalf("Expected error with retries when the connection drops, but was: %v", err)
+	}
+	if reached := atomic.LoadInt32(&attempts); reached < 3 {
+		t.Errorf("Expected the read to be retried, but reached Gocd: %d times", reached)
+	}
+}
+
 func TestClientFetchWhenServerDoesNotRespond(t *testing.T) {
 	client := gocd.NewClient()
 	dashboard, err := client.Fetch("<>")
@@ -168,3 +213,73 @@ func TestClientFetchWhenRequestCreationFails(t *testing.T) {
 		t.Errorf("Expected no invalid dashboard, but was: %#v", dashboard)
 	}
 }
+
+func TestClientWithMaxBodySize(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{ "name": "Group", "pipelines": [] }]`))
+	}))
+	defer ts.Close()
+
+	client := gocd.NewClient(gocd.WithMaxBodySize(16))
+	_, err := client.Fetch(ts.URL)
+	if err == nil || !strings.Contains(err.Error(), "response body larger than 16 bytes") {
+		t.Errorf("Expected error about body size fetching dashboard, but was: %v", err)
+	}
+
+	_, err = client.Agents(ts.URL)
+	if err == nil || !strings.Contains(err.Error(), "response body larger than 16 bytes") {
+		t.Errorf("Expected error about body size fetching agents, but was: %v", err)
+	}
+
+	dashboard, err := gocd.NewClient(gocd.WithMaxBodySize(0)).Fetch(ts.URL)
+	if err != nil || len(dashboard) != 0 {
+		t.Errorf("Expected no limit on body size, but was: %#v (%v)", dashboard, err)
+	}
+}
+
+func TestClientDoesNotLimitBodySizeByDefault(t *testing.T) {
+	padding := strings.Repeat("x", 33<<20)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{ "name": "` + padding + `", "pipelines": [] }]`))
+	}))
+	defer ts.Close()
+
+	dashboard, err := gocd.NewClient().Fetch(ts.URL)
+	if err != nil || len(dashboard) != 0 {
+		t.Errorf("Expected large dashboard to be read, but was: %#v (%v)", dashboard, err)
+	}
+}
+
+func TestClientTruncatesBodyInErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(strings.Repeat("x", 2000)))
+	}))
+	defer ts.Close()
+
+	_, err := gocd.NewClient().Fetch(ts.URL)
+
+	expected := "error fetching response from Gocd: the HTTP status code was 500, body: " + strings.Repeat("x", 512) + "... (truncated)"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected error with truncated body, but was: %v", err)
+	}
+}
+
+func TestClientRejectsHTML(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<!DOCTYPE html><html><body><form action="/go/auth/security_check"></form></body></html>`))
+	}))
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	_, err := client.Fetch(ts.URL)
+	if err == nil || err.Error() != "error authenticating with Gocd at "+ts.URL+": expected JSON but got an HTML page (text/html), probably a login page" {
+		t.Errorf("Expected error about HTML page fetching dashboard, but was: %v", err)
+	}
+
+	_, err = client.Agents(ts.URL)
+	if err == nil || !strings.Contains(err.Error(), "expected JSON but got an HTML page") {
+		t.Errorf("Expected error about HTML page fetching agents, but was: %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+// config_repo.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+type ConfigRepoModification struct {
+	Username     string `json:"username"`
+	EmailAddress string `json:"email_address"`
+	Revision     string `json:"revision"`
+	Comment      string `json:"comment"`
+	ModifiedTime string `json:"modified_time"`
+}
+type ConfigRepoParseInfo struct {
+	Error                    string                  `json:"error"`
+	GoodModification         *ConfigRepoModification `json:"good_modification"`
+	LatestParsedModification *ConfigRepoModification `json:"latest_parsed_modification"`
+}
+
+// ConfigRepo is a repository of pipelines defined as code. Pipelines is not sent by Gocd along with
+// the repository, and is filled by ConfigReposWithPipelines.
+type ConfigRepo struct {
+	ID                       string              `json:"id"`
+	PluginID                 string              `json:"plugin_id"`
+	Material                 MaterialConfig      `json:"material"`
+	MaterialUpdateInProgress bool                `json:"material_update_in_progress"`
+	ParseInfo                ConfigRepoParseInfo `json:"parse_info"`
+	Pipelines                []string            `json:"-"`
+}
+type DashboardConfigRepo struct {
+	ID    string `json:"id"`
+	Error string `json:"error,omitempty"`
+}
+type configReposResponse struct {
+	Embedded struct {
+		ConfigRepos []ConfigRepo `json:"config_repos"`
+	} `json:"_embedded"`
+}
+type configRepoDefinitions struct {
+	Groups []struct {
+		Pipelines []struct {
+			Name string `json:"name"`
+		} `json:"pipelines"`
+	} `json:"groups"`
+}
+
+// HasParseError tells if the latest revision of the repository could not be parsed.
+func (repo ConfigRepo) HasParseError() bool {
+	return repo.ParseInfo.Error != ""
+}
+
+// ConfigRepos lists the config repositories along with the result of their last parse. Version 1 of the
+// config repos API, served before Gocd 19.8.0, carries no parse result, so those repositories never report
+// a parse error. Later versions differ only in attributes not read here.
+func (c Client) ConfigRepos(server string) ([]ConfigRepo, error) {
+	request, err := newGocdRequest("GET", strings.TrimRight(server, "/")+"/go/api/admin/config_repos", c.accept(configReposAPI), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response configReposResponse
+	err = c.performJSON(request, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return response.Embedded.ConfigRepos, nil
+}
+
+// ConfigReposWithPipelines lists the config repositories along with the pipelines each defines,
+// ready to mark a dashboard with WithConfigRepos.
+func (c Client) ConfigReposWithPipelines(server string) ([]ConfigRepo, error) {
+	repos, err := c.ConfigRepos(server)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range repos {
+		repos[i].Pipelines, err = c.ConfigRepoPipelines(server, repos[i].ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return repos, nil
+}
+
+// ConfigRepoPipelines lists the names of the pipelines defined in a config repository.
+func (c Client) ConfigRepoPipelines(server string, id string) ([]string, error) {
+	request, err := newGocdRequest("GET", configRepoURL(server, id)+"/definitions", c.accept(configReposAPI), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var definitions configRepoDefinitions
+	err = c.performJSON(request, &definitions)
+	if err != nil {
+		return nil, err
+	}
+
+	pipelines := []string{}
+	for _, group := range definitions.Groups {
+		for _, pipeline := range group.Pipelines {
+			pipelines = append(pipelines, pipeline.Name)
+		}
+	}
+
+	return pipelines, nil
+}
+
+// TriggerConfigRepoUpdate asks Gocd to fetch and parse the config repository again.
+func (c Client) TriggerConfigRepoUpdate(server string, id string) (string, error) {
+	if id == "" {
+		return "", fmt.Errorf("error triggering config repo update: no config repo given")
+	}
+
+	return c.operation(server, "/go/api/admin/config_repos/"+url.PathEscape(id)+"/trigger_update", c.accept(configReposAPI), nil)
+}
+
+// WithConfigRepos marks the pipelines on the dashboard that are defined in one of the config repositories,
+// along with the parse error of the repository, if any. The repositories must list their pipelines, as
+// those from ConfigReposWithPipelines do.
+func (dashboard Dashboard) WithConfigRepos(repos []ConfigRepo) (marked Dashboard) {
+	for _, pipeline := range dashboard {
+		for _, repo := range repos {
+			if isStringInsideSlice(repo.Pipelines, pipeline.Name) {
+				pipeline.ConfigRepo = &DashboardConfigRepo{ID: repo.ID, Error: repo.ParseInfo.Error}
+				break
+			}
+		}
+		marked = append(marked, pipeline)
+	}
+
+	return
+}
+
+func configRepoURL(server string, id string) string {
+	return strings.TrimRight(server, "/") + "/go/api/admin/config_repos/" + url.PathEscape(id)
+}
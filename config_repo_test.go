@@ -0,0 +1,220 @@
+// config_repo_test.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/chiku/gocd"
+)
+
+func fakeConfigRepoServer(triggered *string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/go/api/admin/config_repos":
+			w.Write([]byte(`{
+				"_embedded": {
+					"config_repos": [{
+						"id": "broken-repo",
+						"plugin_id": "yaml.config.plugin",
+						"material": { "type": "git", "attributes": { "url": "https://example.com/broken.git", "branch": "main", "auto_update": true } },
+						"material_update_in_progress": false,
+						"parse_info": {
+							"error": "Failed to parse file pipelines.gocd.yaml",
+							"good_modification": { "username": "dev", "revision": "aaa111", "comment": "Good" },
+							"latest_parsed_modification": { "username": "dev", "revision": "bbb222", "comment": "Typo" }
+						}
+					}, {
+						"id": "good-repo",
+						"plugin_id": "json.config.plugin",
+						"material": { "type": "git", "attributes": { "url": "https://example.com/good.git", "auto_update": true } },
+						"material_update_in_progress": true,
+						"parse_info": {}
+					}]
+				}
+			}`))
+		case r.Method == "GET" && r.URL.Path == "/go/api/admin/config_repos/broken-repo/definitions":
+			w.Write([]byte(`{
+				"environments": [],
+				"groups": [{ "name": "Group", "pipelines": [{ "name": "Build" }, { "name": "Deploy" }] }]
+			}`))
+		case r.Method == "GET" && r.URL.Path == "/go/api/admin/config_repos/good-repo/definitions":
+			w.Write([]byte(`{ "environments": [], "groups": [{ "name": "Other", "pipelines": [{ "name": "Lint" }] }] }`))
+		case r.Method == "POST" && r.URL.Path == "/go/api/admin/config_repos/broken-repo/trigger_update":
+			*triggered = r.Header.Get("X-GoCD-Confirm")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{ "message": "OK" }`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestClientConfigRepos(t *testing.T) {
+	ts := fakeConfigRepoServer(nil)
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	repos, err := client.ConfigRepos(ts.URL)
+
+	if err != nil {
+		t.Fatalf("Expected no error listing config repos: %s", err)
+	}
+	if len(repos) != 2 {
+		t.Fatalf("Expected 2 config repos, but had %d: %#v", len(repos), repos)
+	}
+
+	broken := repos[0]
+	expectedParseInfo := gocd.ConfigRepoParseInfo{
+		Error:                    "Failed to parse file pipelines.gocd.yaml",
+		GoodModification:         &gocd.ConfigRepoModification{Username: "dev", Revision: "aaa111", Comment: "Good"},
+		LatestParsedModification: &gocd.ConfigRepoModification{Username: "dev", Revision: "bbb222", Comment: "Typo"},
+	}
+	if broken.ID != "broken-repo" || broken.PluginID != "yaml.config.plugin" || broken.Material.Attributes.URL != "https://example.com/broken.git" {
+		t.Errorf("Expected proper config repo, but was: %#v", broken)
+	}
+	if !reflect.DeepEqual(broken.ParseInfo, expectedParseInfo) || !broken.HasParseError() {
+		t.Errorf("Expected parse error (%#v != %#v)", broken.ParseInfo, expectedParseInfo)
+	}
+
+	good := repos[1]
+	if good.HasParseError() || !good.MaterialUpdateInProgress {
+		t.Errorf("Expected config repo without parse error, but was: %#v", good)
+	}
+}
+
+func TestClientConfigReposFromVersion1(t *testing.T) {
+	var accept string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/go/api/version" {
+			w.Write([]byte(`{ "version": "18.6.0" }`))
+			return
+		}
+		accept = r.Header.Get("Accept")
+		w.Write([]byte(`{
+			"_embedded": {
+				"config_repos": [{
+					"id": "repo",
+					"plugin_id": "yaml.config.plugin",
+					"material": { "type": "git", "attributes": { "url": "https://example.com/repo.git", "branch": "master", "auto_update": true } },
+					"configuration": []
+				}]
+			}
+		}`))
+	}))
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	client.DetectServerVersion(ts.URL)
+	repos, err := client.ConfigRepos(ts.URL)
+
+	if err != nil {
+		t.Fatalf("Expected no error listing config repos: %s", err)
+	}
+	if accept != "application/vnd.go.cd.v1+json" {
+		t.Errorf("Expected version 1 accept header, but was: %s", accept)
+	}
+	if len(repos) != 1 || repos[0].ID != "repo" || repos[0].Material.Attributes.URL != "https://example.com/repo.git" || repos[0].HasParseError() {
+		t.Errorf("Expected config repo without parse result, but was: %#v", repos)
+	}
+}
+
+func TestClientConfigRepoPipelines(t *testing.T) {
+	ts := fakeConfigRepoServer(nil)
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	pipelines, err := client.ConfigRepoPipelines(ts.URL, "broken-repo")
+
+	if err != nil {
+		t.Fatalf("Expected no error listing config repo pipelines: %s", err)
+	}
+	if !reflect.DeepEqual(pipelines, []string{"Build", "Deploy"}) {
+		t.Errorf("Expected pipelines from config repo, but was: %v", pipelines)
+	}
+}
+
+func TestClientConfigReposWithPipelines(t *testing.T) {
+	ts := fakeConfigRepoServer(nil)
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	repos, err := client.ConfigReposWithPipelines(ts.URL)
+
+	if err != nil {
+		t.Fatalf("Expected no error listing config repos with pipelines: %s", err)
+	}
+	if len(repos) != 2 || !reflect.DeepEqual(repos[0].Pipelines, []string{"Build", "Deploy"}) || !reflect.DeepEqual(repos[1].Pipelines, []string{"Lint"}) {
+		t.Fatalf("Expected config repos with their pipelines, but was: %#v", repos)
+	}
+
+	dashboard := gocd.Dashboard{{Name: "Build"}, {Name: "Lint"}, {Name: "Manual"}}.WithConfigRepos(repos)
+	expected := gocd.Dashboard{
+		{Name: "Build", ConfigRepo: &gocd.DashboardConfigRepo{ID: "broken-repo", Error: "Failed to parse file pipelines.gocd.yaml"}},
+		{Name: "Lint", ConfigRepo: &gocd.DashboardConfigRepo{ID: "good-repo"}},
+		{Name: "Manual"},
+	}
+	if !reflect.DeepEqual(dashboard, expected) {
+		t.Errorf("Expected dashboard marked with config repos (%#v != %#v)", dashboard, expected)
+	}
+}
+
+func TestClientTriggerConfigRepoUpdate(t *testing.T) {
+	var confirm string
+	ts := fakeConfigRepoServer(&confirm)
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	message, err := client.TriggerConfigRepoUpdate(ts.URL, "broken-repo")
+
+	if err != nil {
+		t.Fatalf("Expected no error triggering config repo update: %s", err)
+	}
+	if message != "OK" || confirm != "true" {
+		t.Errorf("Expected confirmed trigger, but was: %s (confirm: %s)", message, confirm)
+	}
+}
+
+func TestClientTriggerConfigRepoUpdateWithoutID(t *testing.T) {
+	client := gocd.NewClient()
+	_, err := client.TriggerConfigRepoUpdate("http://localhost", "")
+
+	if err == nil || err.Error() != "error triggering config repo update: no config repo given" {
+		t.Errorf("Expected error without config repo, but was: %v", err)
+	}
+}
+
+func TestDashboardWithConfigRepos(t *testing.T) {
+	s1 := []gocd.DashboardStage{{Name: "Stage", Status: "Passed"}}
+	p1 := gocd.DashboardPipeline{Name: "Build", Stages: s1}
+	p2 := gocd.DashboardPipeline{Name: "Manual", Stages: s1}
+	dashboard := gocd.Dashboard{p1, p2}
+
+	repos := []gocd.ConfigRepo{
+		{ID: "broken-repo", ParseInfo: gocd.ConfigRepoParseInfo{Error: "Parse failure"}, Pipelines: []string{"build"}},
+	}
+	marked := dashboard.WithConfigRepos(repos)
+
+	if marked[0].ConfigRepo == nil || *marked[0].ConfigRepo != (gocd.DashboardConfigRepo{ID: "broken-repo", Error: "Parse failure"}) {
+		t.Errorf("Expected pipeline from config repo to be marked, but was: %#v", marked[0])
+	}
+	if marked[1].ConfigRepo != nil {
+		t.Errorf("Expected pipeline outside config repos to be unmarked, but was: %#v", marked[1])
+	}
+
+	body, err := marked.ToJSON()
+	if err != nil {
+		t.Fatalf("Expected no error marshalling dashboard to JSON: %s", err)
+	}
+	expected := `[{"name":"Build","stages":[{"name":"Stage","status":"Passed"}],"config_repo":{"id":"broken-repo","error":"Parse failure"}},{"name":"Manual","stages":[{"name":"Stage","status":"Passed"}]}]`
+	if string(body) != expected {
+		t.Errorf("Expected config repo next to its pipelines, but was: %s", body)
+	}
+}
@@ -0,0 +1,106 @@
+// console.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// JobLocator identifies a single job inside a stage run.
+type JobLocator struct {
+	StageLocator
+	JobName string
+}
+
+func (job JobLocator) filesPath() string {
+	return fmt.Sprintf("/go/files/%s/%d/%s/%d/%s",
+		url.PathEscape(job.PipelineName), job.PipelineCounter,
+		url.PathEscape(job.StageName), job.StageCounter,
+		url.PathEscape(job.JobName))
+}
+
+// ConsoleLog streams the console output of a job, starting at the given byte offset.
+// A running job can be tailed by calling it again with the count of bytes already read, which
+// gives an empty log while there is no new output.
+// The caller must close the returned reader.
+func (c Client) ConsoleLog(server string, job JobLocator, offset int64) (io.ReadCloser, error) {
+	if offset < 0 {
+		return nil, fmt.Errorf("error fetching console log: negative offset %d", offset)
+	}
+
+	request, err := http.NewRequest("GET", strings.TrimRight(server, "/")+job.filesPath()+"/cruise-output/console.log", nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating Gocd request: %s", err)
+	}
+	if offset > 0 {
+		request.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	response, err := c.send(request)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching data from Gocd: %s", err)
+	}
+	// Gocd answers a range starting at the end of the log as unsatisfiable, which means no new output
+	if offset > 0 && response.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		response.Body.Close()
+		return ioutil.NopCloser(strings.NewReader("")), nil
+	}
+
+	response, err = streamHTTPResponse(response)
+	if err != nil {
+		return nil, err
+	}
+
+	if offset > 0 && response.StatusCode != http.StatusPartialContent {
+		_, err = io.CopyN(ioutil.Discard, response.Body, offset)
+		if err != nil && err != io.EOF {
+			response.Body.Close()
+			return nil, fmt.Errorf("error skipping to offset %d in console log: %s", offset, err)
+		}
+	}
+
+	return response.Body, nil
+}
+
+// TailLines returns up to the last n lines read from reader, oldest first.
+// Only n lines are held in memory at any time, however long the log is.
+func TailLines(reader io.Reader, n int) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	ring := make([]string, n)
+	count := 0
+	buffered := bufio.NewReader(reader)
+
+	for {
+		line, err := buffered.ReadString('\n')
+		if line != "" {
+			ring[count%n] = strings.TrimRight(line, "\r\n")
+			count++
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading console log: %s", err)
+		}
+	}
+
+	if count <= n {
+		return ring[:count], nil
+	}
+
+	start := count % n
+	return append(ring[start:], ring[:start]...), nil
+}
@@ -0,0 +1,171 @@
+// console_test.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chiku/gocd"
+)
+
+const consoleLog = "line one\nline two\nline three\n"
+
+var consoleJob = gocd.JobLocator{
+	StageLocator: gocd.StageLocator{PipelineName: "Pipeline", PipelineCounter: 3, StageName: "Stage", StageCounter: 1},
+	JobName:      "Job",
+}
+
+func TestClientConsoleLog(t *testing.T) {
+	var path string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Write([]byte(consoleLog))
+	}))
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	reader, err := client.ConsoleLog(ts.URL, consoleJob, 0)
+	if err != nil {
+		t.Fatalf("Expected no error fetching console log: %s", err)
+	}
+	defer reader.Close()
+
+	body, _ := ioutil.ReadAll(reader)
+	if string(body) != consoleLog {
+		t.Errorf("Expected full console log, but was: %q", body)
+	}
+	if path != "/go/files/Pipeline/3/Stage/1/Job/cruise-output/console.log" {
+		t.Errorf("Expected proper console log path, but was: %s", path)
+	}
+}
+
+func TestClientConsoleLogFromOffsetWithRangeSupport(t *testing.T) {
+	var rangeHeader string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rangeHeader = r.Header.Get("Range")
+		http.ServeContent(w, r, "console.log", time.Time{}, strings.NewReader(consoleLog))
+	}))
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	reader, err := client.ConsoleLog(ts.URL, consoleJob, 9)
+	if err != nil {
+		t.Fatalf("Expected no error fetching console log: %s", err)
+	}
+	defer reader.Close()
+
+	body, _ := ioutil.ReadAll(reader)
+	if string(body) != "line two\nline three\n" {
+		t.Errorf("Expected console log from offset, but was: %q", body)
+	}
+	if rangeHeader != "bytes=9-" {
+		t.Errorf("Expected range header, but was: %q", rangeHeader)
+	}
+}
+
+func TestClientConsoleLogFromOffsetWithoutNewOutput(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "console.log", time.Time{}, strings.NewReader(consoleLog))
+	}))
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	reader, err := client.ConsoleLog(ts.URL, consoleJob, int64(len(consoleLog)))
+	if err != nil {
+		t.Fatalf("Expected no error tailing console log without new output: %s", err)
+	}
+	defer reader.Close()
+
+	body, _ := ioutil.ReadAll(reader)
+	if len(body) != 0 {
+		t.Errorf("Expected empty console log, but was: %q", body)
+	}
+}
+
+func TestClientConsoleLogFromOffsetWithoutRangeSupport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(consoleLog))
+	}))
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	reader, err := client.ConsoleLog(ts.URL, consoleJob, 9)
+	if err != nil {
+		t.Fatalf("Expected no error fetching console log: %s", err)
+	}
+	defer reader.Close()
+
+	body, _ := ioutil.ReadAll(reader)
+	if string(body) != "line two\nline three\n" {
+		t.Errorf("Expected console log from offset, but was: %q", body)
+	}
+}
+
+func TestClientConsoleLogWhenServerResponseNot200(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("Not found"))
+	}))
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	reader, err := client.ConsoleLog(ts.URL, consoleJob, 0)
+
+	if err == nil || err.Error() != "error fetching response from Gocd: the HTTP status code was 404, body: Not found" {
+		t.Errorf("Expected proper error message but was: %v", err)
+	}
+	if reader != nil {
+		t.Errorf("Expected no reader, but was: %#v", reader)
+	}
+}
+
+func TestClientConsoleLogWithNegativeOffset(t *testing.T) {
+	client := gocd.NewClient()
+	_, err := client.ConsoleLog("http://localhost", consoleJob, -1)
+
+	if err == nil || err.Error() != "error fetching console log: negative offset -1" {
+		t.Errorf("Expected proper error message but was: %v", err)
+	}
+}
+
+func TestTailLines(t *testing.T) {
+	lines, err := gocd.TailLines(strings.NewReader("one\ntwo\r\nthree\nfour\nfive"), 3)
+
+	if err != nil {
+		t.Fatalf("Expected no error tailing lines: %s", err)
+	}
+	expected := []string{"three", "four", "five"}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected last lines (%v != %v)", lines, expected)
+	}
+}
+
+func TestTailLinesWithFewerLinesThanRequested(t *testing.T) {
+	lines, err := gocd.TailLines(strings.NewReader(consoleLog), 10)
+
+	if err != nil {
+		t.Fatalf("Expected no error tailing lines: %s", err)
+	}
+	expected := []string{"line one", "line two", "line three"}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected all lines (%v != %v)", lines, expected)
+	}
+}
+
+func TestTailLinesWithZeroLines(t *testing.T) {
+	lines, err := gocd.TailLines(strings.NewReader(consoleLog), 0)
+
+	if err != nil || lines != nil {
+		t.Errorf("Expected no lines and no error, but was: %v, %v", lines, err)
+	}
+}
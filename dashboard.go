@@ -10,18 +10,35 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 )
 
 type DashboardStage struct {
 	Name   string `json:"name"`
 	Status string `json:"status"`
 }
+type DashboardMaterial struct {
+	Type         string     `json:"type"`
+	Description  string     `json:"description"`
+	Revision     string     `json:"revision,omitempty"`
+	Author       string     `json:"author,omitempty"`
+	Message      string     `json:"message,omitempty"`
+	ModifiedTime *time.Time `json:"modified_time,omitempty"`
+	Changed      bool       `json:"changed"`
+}
 type DashboardPipeline struct {
-	Name   string           `json:"name"`
-	Stages []DashboardStage `json:"stages"`
-	order  int
+	Name       string               `json:"name"`
+	Stages     []DashboardStage     `json:"stages"`
+	Materials  []DashboardMaterial  `json:"materials,omitempty"`
+	ConfigRepo *DashboardConfigRepo `json:"config_repo,omitempty"`
+	order      int
 }
 type Dashboard []DashboardPipeline
+type RunningStage struct {
+	Pipeline string
+	Stage    string
+	Agents   []Agent
+}
 
 func (dashboard Dashboard) ToJSON() (output []byte, err error) {
 	output, err = json.Marshal(dashboard)
@@ -49,12 +66,46 @@ func (dashboard Dashboard) FilteredSort(order []string) (sortedDashboard Dashboa
 	return
 }
 
+// FlowSort orders the dashboard from upstream to downstream pipelines, instead of a hand-maintained list.
+// Pipelines missing from the graph are ignored, just as FilteredSort does for pipelines not in its order.
+func (dashboard Dashboard) FlowSort(graph DependencyGraph) (sortedDashboard Dashboard, ignores []string, err error) {
+	order, err := graph.TopologicalOrder()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sortedDashboard, ignores = dashboard.FilteredSort(order)
+	return sortedDashboard, ignores, nil
+}
+
 func (dashboard Dashboard) MapNames(mapping map[string]string) (mappedDashboard Dashboard) {
 	for _, pipeline := range dashboard {
 		if val, ok := mapping[pipeline.Name]; ok {
-			mappedDashboard = append(mappedDashboard, DashboardPipeline{Name: val, Stages: pipeline.Stages})
-		} else {
-			mappedDashboard = append(mappedDashboard, pipeline)
+			pipeline.Name = val
+		}
+		mappedDashboard = append(mappedDashboard, pipeline)
+	}
+
+	return
+}
+
+// RunningStages pairs every building stage on the dashboard with the agents building its jobs.
+// Use it before MapNames, as agents report the pipeline names known to Gocd.
+func (dashboard Dashboard) RunningStages(agents []Agent) (running []RunningStage) {
+	for _, pipeline := range dashboard {
+		for _, stage := range pipeline.Stages {
+			if !strings.EqualFold(stage.Status, building) && !strings.EqualFold(stage.Status, recovering) {
+				continue
+			}
+
+			runningStage := RunningStage{Pipeline: pipeline.Name, Stage: stage.Name}
+			for _, agent := range agents {
+				details := agent.BuildDetails
+				if details != nil && strings.EqualFold(details.PipelineName, pipeline.Name) && strings.EqualFold(details.StageName, stage.Name) {
+					runningStage.Agents = append(runningStage.Agents, agent)
+				}
+			}
+			running = append(running, runningStage)
 		}
 	}
 
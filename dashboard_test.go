@@ -228,3 +228,53 @@ func TestDashboardMapNamesWhenNoMatch(t *testing.T) {
 		t.Errorf("Expected second stage to have original name, but was: %#v", item1)
 	}
 }
+
+func TestDashboardRunningStages(t *testing.T) {
+	s1 := []gocd.DashboardStage{gocd.DashboardStage{Name: "Build", Status: "Passed"}, gocd.DashboardStage{Name: "Test", Status: "Building"}}
+	s2 := []gocd.DashboardStage{gocd.DashboardStage{Name: "Deploy", Status: "Recovering"}}
+	s3 := []gocd.DashboardStage{gocd.DashboardStage{Name: "Build", Status: "Failed"}}
+	p1 := gocd.DashboardPipeline{Name: "Pipeline One", Stages: s1}
+	p2 := gocd.DashboardPipeline{Name: "Pipeline Two", Stages: s2}
+	p3 := gocd.DashboardPipeline{Name: "Pipeline Three", Stages: s3}
+	dashboard := gocd.Dashboard{p1, p2, p3}
+
+	a1 := gocd.Agent{UUID: "a1", BuildDetails: &gocd.AgentBuildDetails{PipelineName: "Pipeline One", StageName: "Test", JobName: "unit"}}
+	a2 := gocd.Agent{UUID: "a2", BuildDetails: &gocd.AgentBuildDetails{PipelineName: "pipeline one", StageName: "test", JobName: "lint"}}
+	a3 := gocd.Agent{UUID: "a3"}
+	running := dashboard.RunningStages([]gocd.Agent{a1, a2, a3})
+
+	expected := []gocd.RunningStage{
+		{Pipeline: "Pipeline One", Stage: "Test", Agents: []gocd.Agent{a1, a2}},
+		{Pipeline: "Pipeline Two", Stage: "Deploy"},
+	}
+	if !reflect.DeepEqual(running, expected) {
+		t.Errorf("Expected running stages with their agents (%#v != %#v)", running, expected)
+	}
+}
+
+func TestDashboardFlowSort(t *testing.T) {
+	s1 := []gocd.DashboardStage{gocd.DashboardStage{Name: "Stage", Status: "Passed"}}
+	p1 := gocd.DashboardPipeline{Name: "Deploy", Stages: s1}
+	p2 := gocd.DashboardPipeline{Name: "Build", Stages: s1}
+	p3 := gocd.DashboardPipeline{Name: "Unrelated", Stages: s1}
+	dashboard := gocd.Dashboard{p1, p3, p2}
+
+	build := gocd.PipelineConfig{Name: "Build"}
+	deploy := gocd.PipelineConfig{
+		Name:      "Deploy",
+		Materials: []gocd.MaterialConfig{{Type: "dependency", Attributes: gocd.MaterialAttributes{Pipeline: "Build", Stage: "Stage"}}},
+	}
+	graph := gocd.NewDependencyGraph([]gocd.PipelineConfig{deploy, build})
+
+	sortedDashboard, ignores, err := dashboard.FlowSort(graph)
+
+	if err != nil {
+		t.Fatalf("Expected no error sorting by flow: %s", err)
+	}
+	if !reflect.DeepEqual(sortedDashboard, gocd.Dashboard{p2, p1}) {
+		t.Errorf("Expected upstream pipelines first, but was: %#v", sortedDashboard)
+	}
+	if !reflect.DeepEqual(ignores, []string{"Unrelated"}) {
+		t.Errorf("Expected pipelines outside the graph to be ignored, but was: %#v", ignores)
+	}
+}
@@ -0,0 +1,171 @@
+// decoder.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// NewDashboardFromReader reads the legacy dashboard JSON as a stream, turning each pipeline into its
+// dashboard entry as soon as it is read. Unlike NewPipelineGroups followed by ToDashboard, it never holds
+// the whole response, nor the build causes of older instances, in memory.
+func NewDashboardFromReader(reader io.Reader) (Dashboard, error) {
+	decoder := json.NewDecoder(reader)
+	dashboard, err := decodeDashboard(decoder)
+	if err == nil {
+		if _, trailing := decoder.Token(); trailing != io.EOF {
+			err = fmt.Errorf("unexpected data after the pipeline groups")
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling Gocd JSON: %s", err)
+	}
+
+	return dashboard, nil
+}
+
+func decodeDashboard(decoder *json.Decoder) (Dashboard, error) {
+	dashboard := Dashboard{}
+	err := decodeArray(decoder, func() error {
+		return decodeObject(decoder, func(key string) error {
+			if !strings.EqualFold(key, "pipelines") {
+				return skipValue(decoder)
+			}
+
+			return decodeArray(decoder, func() error {
+				pipeline, err := decodePipeline(decoder)
+				if err != nil {
+					return err
+				}
+				if dashboardPipeline, ok := pipeline.toDashboard(); ok {
+					dashboard = append(dashboard, dashboardPipeline)
+				}
+				return nil
+			})
+		})
+	})
+
+	return dashboard, err
+}
+
+// decodePipeline decodes the build cause of the latest instance only, as older ones are not shown.
+// Build causes are held raw until the latest instance is known, in a buffer reused across instances.
+func decodePipeline(decoder *json.Decoder) (Pipeline, error) {
+	var pipeline Pipeline
+	err := decodeObject(decoder, func(key string) error {
+		switch strings.ToLower(key) {
+		case "name":
+			return decoder.Decode(&pipeline.Name)
+		case "previous_instance":
+			return decoder.Decode(&pipeline.PreviousInstance)
+		case "instances":
+			return decodeInstances(decoder, &pipeline)
+		default:
+			return skipValue(decoder)
+		}
+	})
+
+	return pipeline, err
+}
+
+func decodeInstances(decoder *json.Decoder, pipeline *Pipeline) error {
+	var buildCause json.RawMessage
+	err := decodeArray(decoder, func() error {
+		instance := struct {
+			Stages     []Stage         `json:"stages"`
+			BuildCause json.RawMessage `json:"build_cause"`
+		}{BuildCause: buildCause[:0]}
+		err := decoder.Decode(&instance)
+		if err != nil {
+			return err
+		}
+		buildCause = instance.BuildCause
+		pipeline.Instances = append(pipeline.Instances, Instance{Stages: instance.Stages})
+		return nil
+	})
+	if err != nil || len(pipeline.Instances) == 0 || len(buildCause) == 0 {
+		return err
+	}
+
+	return json.Unmarshal(buildCause, &pipeline.Instances[len(pipeline.Instances)-1].BuildCause)
+}
+
+// decodeArray calls each for every element of the array read next, and reads null as an empty array.
+func decodeArray(decoder *json.Decoder, each func() error) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return nil
+	}
+	if token != json.Delim('[') {
+		return fmt.Errorf("expected an array, but was: %v", token)
+	}
+
+	for decoder.More() {
+		err = each()
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = decoder.Token()
+	return err
+}
+
+// decodeObject calls each with the key of every member of the object read next, which must read its value.
+// It reads null as an empty object.
+func decodeObject(decoder *json.Decoder, each func(key string) error) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return nil
+	}
+	if token != json.Delim('{') {
+		return fmt.Errorf("expected an object, but was: %v", token)
+	}
+
+	for decoder.More() {
+		token, err = decoder.Token()
+		if err != nil {
+			return err
+		}
+		err = each(token.(string))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = decoder.Token()
+	return err
+}
+
+func skipValue(decoder *json.Decoder) error {
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+
+		switch token {
+		case json.Delim('['), json.Delim('{'):
+			depth++
+		case json.Delim(']'), json.Delim('}'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
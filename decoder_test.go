@@ -0,0 +1,169 @@
+// decoder_test.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chiku/gocd"
+)
+
+const streamedDashboardResponse = `[
+	{ "name": "Empty", "pipelines": null },
+	{
+		"name": "Group",
+		"extra": { "nested": [1, {"deep": [true, null]}], "text": "]}" },
+		"pipelines": [
+			{
+				"name": "Build",
+				"label": "${COUNT}",
+				"instances": [
+					{ "label": "1", "stages": [{ "name": "Test", "status": "Failed" }], "build_cause": { "material_revisions": [{ "material": { "type": "Git", "description": "Old" } }] } },
+					{ "label": "2", "stages": [{ "name": "Test", "status": "Unknown", "approved_by": "changes" }], "build_cause": { "material_revisions": [{ "changed": true, "material": { "type": "Git", "description": "New" }, "modifications": [{ "revision": "abc", "user_name": "dev", "modified_time": 1500000000000 }] }] } }
+				],
+				"previous_instance": { "result": "Passed", "recent_run": 5 }
+			},
+			{ "name": "Idle", "instances": [] },
+			{ "name": "Deploy", "instances": [{ "stages": [{ "name": "Deploy", "status": "Building" }] }], "previous_instance": { "result": "Failed" } },
+			{
+				"name": "Release",
+				"instances": [
+					{ "stages": [{ "name": "Release", "status": "Passed" }], "build_cause": { "material_revisions": [{ "material": { "type": "Git", "description": "Older and longer" } }] } },
+					{ "stages": [{ "name": "Release", "status": "Passed" }] }
+				]
+			}
+		]
+	}
+]`
+
+func TestNewDashboardFromReader(t *testing.T) {
+	groups, err := gocd.NewPipelineGroups([]byte(streamedDashboardResponse))
+	if err != nil {
+		t.Fatalf("Expected no error creating pipeline groups: %s", err)
+	}
+	expected := groups.ToDashboard()
+
+	dashboard, err := gocd.NewDashboardFromReader(strings.NewReader(streamedDashboardResponse))
+
+	if err != nil {
+		t.Fatalf("Expected no error streaming dashboard: %s", err)
+	}
+	if !reflect.DeepEqual(dashboard, expected) {
+		t.Errorf("Expected streamed dashboard to match the parsed dashboard (%#v != %#v)", dashboard, expected)
+	}
+	if len(dashboard) != 3 || dashboard[0].Stages[0].Status != "Failed" || dashboard[0].Materials[0].Description != "New" || dashboard[1].Stages[0].Status != "Recovering" || dashboard[2].Materials != nil {
+		t.Errorf("Expected proper dashboard, but was: %#v", dashboard)
+	}
+}
+
+func TestNewDashboardFromReaderWithoutPipelineGroups(t *testing.T) {
+	for _, body := range []string{`[]`, `null`} {
+		dashboard, err := gocd.NewDashboardFromReader(strings.NewReader(body))
+
+		if err != nil || dashboard == nil || len(dashboard) != 0 {
+			t.Errorf("Expected empty dashboard for %s, but was: %#v (%v)", body, dashboard, err)
+		}
+	}
+}
+
+func TestNewDashboardFromReaderOnError(t *testing.T) {
+	for _, body := range []string{
+		``,
+		`Random`,
+		`{}`,
+		`[{ "pipelines": {} }]`,
+		`[{ "pipelines": [{ "name": 5 }] }]`,
+		`[{ "pipelines": [{ "instances": [{ "stages": "none" }] }] }]`,
+		`[{ "pipelines": [{ "instances": [{ "stages": [], "build_cause": { "material_revisions": 5 } }] }] }]`,
+		`[{ "pipelines": [`,
+		`[] []`,
+	} {
+		dashboard, err := gocd.NewDashboardFromReader(strings.NewReader(body))
+
+		if err == nil || !strings.Contains(err.Error(), "error unmarshalling Gocd JSON: ") {
+			t.Errorf("Expected error message about JSON unmarshall error for %q, but was: %v", body, err)
+		}
+		if dashboard != nil {
+			t.Errorf("Expected no invalid dashboard for %q, but was: %#v", body, dashboard)
+		}
+	}
+}
+
+func largeDashboardResponse(pipelines int, instances int) []byte {
+	var out bytes.Buffer
+	out.WriteString(`[{ "name": "Group", "pipelines": [`)
+	for p := 0; p < pipelines; p++ {
+		if p > 0 {
+			out.WriteString(",")
+		}
+		fmt.Fprintf(&out, `{ "name": "Pipeline %d", "instances": [`, p)
+		for i := 0; i < instances; i++ {
+			if i > 0 {
+				out.WriteString(",")
+			}
+			fmt.Fprintf(&out, `{ "label": "%d", "stages": [{ "name": "Compile", "status": "Passed" }, { "name": "Test", "status": "Unknown" }], `, i)
+			out.WriteString(`"build_cause": { "trigger_message": "modified by dev", "material_revisions": [{ "changed": true, "material": { "type": "Git", "description": "URL: https://example.com/repo.git, Branch: master" }, `)
+			out.WriteString(`"modifications": [{ "revision": "9f8e7d6c5b4a39281706f5e4d3c2b1a098765432", "user_name": "dev <dev@example.com>", "comment": "A change with a fairly long commit message", "modified_time": 1500000000000 }] }] } }`)
+		}
+		out.WriteString(`], "previous_instance": { "result": "Passed" } }`)
+	}
+	out.WriteString(`] }]`)
+	return out.Bytes()
+}
+
+func BenchmarkParseDashboard(b *testing.B) {
+	body := largeDashboardResponse(200, 10)
+	b.SetBytes(int64(len(body)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		read, _ := ioutil.ReadAll(bytes.NewReader(body))
+		groups, err := gocd.NewPipelineGroups(read)
+		if err != nil {
+			b.Fatal(err)
+		}
+		groups.ToDashboard()
+	}
+}
+
+func BenchmarkStreamDashboard(b *testing.B) {
+	body := largeDashboardResponse(200, 10)
+	b.SetBytes(int64(len(body)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, err := gocd.NewDashboardFromReader(bytes.NewReader(body))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func FuzzNewDashboardFromReader(f *testing.F) {
+	f.Add(streamedDashboardResponse)
+	f.Add(`[{ "pipelines": [{ "instances": [null, { "stages": null }] }] }]`)
+	f.Add(`[{ "pipelines": [`)
+
+	f.Fuzz(func(t *testing.T, body string) {
+		dashboard, err := gocd.NewDashboardFromReader(strings.NewReader(body))
+		if err != nil && dashboard != nil {
+			t.Errorf("Expected no invalid dashboard, but was: %#v", dashboard)
+		}
+		for _, pipeline := range dashboard {
+			if len(pipeline.Stages) == 0 {
+				t.Errorf("Expected no pipeline without stages on dashboard, but was: %#v", pipeline)
+			}
+		}
+	})
+}
@@ -0,0 +1,179 @@
+// dependency.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd
+
+import (
+	"fmt"
+	"strings"
+)
+
+const dependencyMaterial = "dependency"
+
+// DependencyGraph links pipelines through the dependency materials in their configs.
+// Pipeline names are matched case-insensitively, as Gocd does.
+type DependencyGraph struct {
+	pipelines  []string
+	names      map[string]string
+	upstream   map[string][]string
+	downstream map[string][]string
+}
+
+// CycleError is returned when pipelines depend on each other in a loop.
+type CycleError struct {
+	Pipelines []string
+}
+
+func (err *CycleError) Error() string {
+	return fmt.Sprintf("error ordering pipelines: dependency cycle %s", strings.Join(err.Pipelines, " -> "))
+}
+
+// NewDependencyGraph builds the graph from pipeline configs. Upstream pipelines without a config
+// are part of the graph, so that their downstream pipelines are still ordered after them.
+func NewDependencyGraph(configs []PipelineConfig) DependencyGraph {
+	graph := DependencyGraph{
+		names:      map[string]string{},
+		upstream:   map[string][]string{},
+		downstream: map[string][]string{},
+	}
+
+	for _, config := range configs {
+		graph.add(config.Name)
+	}
+	for _, config := range configs {
+		for _, material := range config.Materials {
+			if material.Type != dependencyMaterial || material.Attributes.Pipeline == "" {
+				continue
+			}
+			from := graph.add(material.Attributes.Pipeline)
+			to := strings.ToLower(config.Name)
+			graph.upstream[to] = appendUnique(graph.upstream[to], from)
+			graph.downstream[from] = appendUnique(graph.downstream[from], to)
+		}
+	}
+
+	return graph
+}
+
+// Pipelines lists all pipelines in the graph, in the order they were first seen.
+func (graph DependencyGraph) Pipelines() []string {
+	return graph.display(graph.pipelines)
+}
+
+// Upstream lists the pipelines the given pipeline directly depends on.
+func (graph DependencyGraph) Upstream(pipeline string) []string {
+	return graph.display(graph.upstream[strings.ToLower(pipeline)])
+}
+
+// Downstream lists the pipelines directly depending on the given pipeline.
+func (graph DependencyGraph) Downstream(pipeline string) []string {
+	return graph.display(graph.downstream[strings.ToLower(pipeline)])
+}
+
+// Cycle returns a dependency loop, starting and ending with the same pipeline, or nil when there is none.
+func (graph DependencyGraph) Cycle() []string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	var path []string
+
+	var visit func(string) []string
+	visit = func(pipeline string) []string {
+		state[pipeline] = visiting
+		path = append(path, pipeline)
+
+		for _, next := range graph.downstream[pipeline] {
+			switch state[next] {
+			case visiting:
+				for i, p := range path {
+					if p == next {
+						return append(append([]string{}, path[i:]...), next)
+					}
+				}
+			case unvisited:
+				if cycle := visit(next); cycle != nil {
+					return cycle
+				}
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[pipeline] = visited
+		return nil
+	}
+
+	for _, pipeline := range graph.pipelines {
+		if state[pipeline] == unvisited {
+			if cycle := visit(pipeline); cycle != nil {
+				return graph.display(cycle)
+			}
+		}
+	}
+
+	return nil
+}
+
+// TopologicalOrder lists all pipelines so that every pipeline comes after the pipelines it depends on.
+// Independent pipelines keep the order they were first seen in. A loop results in a *CycleError.
+func (graph DependencyGraph) TopologicalOrder() ([]string, error) {
+	if cycle := graph.Cycle(); cycle != nil {
+		return nil, &CycleError{Pipelines: cycle}
+	}
+
+	pending := map[string]int{}
+	for _, pipeline := range graph.pipelines {
+		pending[pipeline] = len(graph.upstream[pipeline])
+	}
+
+	var order []string
+	done := map[string]bool{}
+	for len(order) < len(graph.pipelines) {
+		for _, pipeline := range graph.pipelines {
+			if done[pipeline] || pending[pipeline] > 0 {
+				continue
+			}
+			done[pipeline] = true
+			order = append(order, pipeline)
+			for _, next := range graph.downstream[pipeline] {
+				pending[next]--
+			}
+			break
+		}
+	}
+
+	return graph.display(order), nil
+}
+
+func (graph *DependencyGraph) add(pipeline string) string {
+	key := strings.ToLower(pipeline)
+	if _, ok := graph.names[key]; !ok {
+		graph.names[key] = pipeline
+		graph.pipelines = append(graph.pipelines, key)
+	}
+
+	return key
+}
+
+func (graph DependencyGraph) display(keys []string) (names []string) {
+	for _, key := range keys {
+		names = append(names, graph.names[key])
+	}
+
+	return
+}
+
+func appendUnique(slice []string, item string) []string {
+	for _, s := range slice {
+		if s == item {
+			return slice
+		}
+	}
+
+	return append(slice, item)
+}
@@ -0,0 +1,92 @@
+// dependency_test.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/chiku/gocd"
+)
+
+func pipelineConfigWithUpstreams(name string, upstreams ...string) gocd.PipelineConfig {
+	config := gocd.PipelineConfig{
+		Name:      name,
+		Materials: []gocd.MaterialConfig{{Type: "git", Attributes: gocd.MaterialAttributes{URL: "https://example.com/repo.git"}}},
+	}
+	for _, upstream := range upstreams {
+		material := gocd.MaterialConfig{Type: "dependency", Attributes: gocd.MaterialAttributes{Pipeline: upstream, Stage: "Stage"}}
+		config.Materials = append(config.Materials, material)
+	}
+	return config
+}
+
+func TestDependencyGraph(t *testing.T) {
+	configs := []gocd.PipelineConfig{
+		pipelineConfigWithUpstreams("Prod", "Staging"),
+		pipelineConfigWithUpstreams("Staging", "build", "Docs"),
+		pipelineConfigWithUpstreams("Build"),
+		pipelineConfigWithUpstreams("Docs", "Build"),
+		pipelineConfigWithUpstreams("Lint"),
+	}
+	graph := gocd.NewDependencyGraph(configs)
+
+	if upstream := graph.Upstream("staging"); !reflect.DeepEqual(upstream, []string{"Build", "Docs"}) {
+		t.Errorf("Expected upstream pipelines of Staging, but was: %v", upstream)
+	}
+	if downstream := graph.Downstream("Build"); !reflect.DeepEqual(downstream, []string{"Staging", "Docs"}) {
+		t.Errorf("Expected downstream pipelines of Build, but was: %v", downstream)
+	}
+	if cycle := graph.Cycle(); cycle != nil {
+		t.Errorf("Expected no cycle, but was: %v", cycle)
+	}
+
+	order, err := graph.TopologicalOrder()
+	if err != nil {
+		t.Fatalf("Expected no error ordering pipelines: %s", err)
+	}
+	expected := []string{"Build", "Docs", "Staging", "Prod", "Lint"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected upstream to downstream order (%v != %v)", order, expected)
+	}
+}
+
+func TestDependencyGraphWithUnconfiguredUpstream(t *testing.T) {
+	graph := gocd.NewDependencyGraph([]gocd.PipelineConfig{pipelineConfigWithUpstreams("Deploy", "External")})
+
+	order, err := graph.TopologicalOrder()
+	if err != nil {
+		t.Fatalf("Expected no error ordering pipelines: %s", err)
+	}
+	if !reflect.DeepEqual(order, []string{"External", "Deploy"}) {
+		t.Errorf("Expected unconfigured upstream first, but was: %v", order)
+	}
+}
+
+func TestDependencyGraphWithCycle(t *testing.T) {
+	configs := []gocd.PipelineConfig{
+		pipelineConfigWithUpstreams("A"),
+		pipelineConfigWithUpstreams("B", "A", "D"),
+		pipelineConfigWithUpstreams("C", "B"),
+		pipelineConfigWithUpstreams("D", "C"),
+	}
+	graph := gocd.NewDependencyGraph(configs)
+
+	expectedCycle := []string{"B", "C", "D", "B"}
+	if cycle := graph.Cycle(); !reflect.DeepEqual(cycle, expectedCycle) {
+		t.Errorf("Expected cycle (%v != %v)", cycle, expectedCycle)
+	}
+
+	order, err := graph.TopologicalOrder()
+	cycleErr, ok := err.(*gocd.CycleError)
+	if !ok || order != nil {
+		t.Fatalf("Expected cycle error, but was: %v, %v", order, err)
+	}
+	if cycleErr.Error() != "error ordering pipelines: dependency cycle B -> C -> D -> B" {
+		t.Errorf("Expected proper error message but was: %s", cycleErr.Error())
+	}
+}
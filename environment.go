@@ -0,0 +1,116 @@
+// environment.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+type Environment struct {
+	Name                 string
+	Pipelines            []string
+	Agents               []string
+	EnvironmentVariables []EnvironmentVariable
+}
+type EnvironmentDashboard struct {
+	Environment string    `json:"environment"`
+	Dashboard   Dashboard `json:"pipelines"`
+}
+type environmentsResponse struct {
+	Embedded struct {
+		Environments []Environment `json:"environments"`
+	} `json:"_embedded"`
+}
+
+// UnmarshalJSON flattens the pipeline and agent references Gocd sends into their names and UUIDs.
+func (environment *Environment) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Name      string `json:"name"`
+		Pipelines []struct {
+			Name string `json:"name"`
+		} `json:"pipelines"`
+		Agents []struct {
+			UUID string `json:"uuid"`
+		} `json:"agents"`
+		EnvironmentVariables []EnvironmentVariable `json:"environment_variables"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*environment = Environment{Name: raw.Name, EnvironmentVariables: raw.EnvironmentVariables}
+	for _, pipeline := range raw.Pipelines {
+		environment.Pipelines = append(environment.Pipelines, pipeline.Name)
+	}
+	for _, agent := range raw.Agents {
+		environment.Agents = append(environment.Agents, agent.UUID)
+	}
+	return nil
+}
+
+// Environments lists the environments with their pipelines, agents and variables. Version 2 of the
+// environments API lists the agents of each environment, but later versions do not, so their agents
+// are found through the environments of each agent.
+func (c Client) Environments(server string) ([]Environment, error) {
+	version := c.apiVersion(environmentsAPI)
+	request, err := newGocdRequest("GET", strings.TrimRight(server, "/")+"/go/api/admin/environments", acceptVersion(version), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response environmentsResponse
+	err = c.performJSON(request, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	environments := response.Embedded.Environments
+	if version == "v2" || len(environments) == 0 {
+		return environments, nil
+	}
+
+	agents, err := c.Agents(server)
+	if err != nil {
+		return nil, err
+	}
+
+	return withAgents(environments, agents), nil
+}
+
+func withAgents(environments []Environment, agents []Agent) []Environment {
+	for i := range environments {
+		for _, agent := range agents {
+			if isStringInsideSlice(agent.Environments, environments[i].Name) {
+				environments[i].Agents = append(environments[i].Agents, agent.UUID)
+			}
+		}
+	}
+
+	return environments
+}
+
+// ForEnvironment keeps only the pipelines on the dashboard that belong to the environment.
+func (dashboard Dashboard) ForEnvironment(environment Environment) (environmentDashboard Dashboard) {
+	for _, pipeline := range dashboard {
+		if isStringInsideSlice(environment.Pipelines, pipeline.Name) {
+			environmentDashboard = append(environmentDashboard, pipeline)
+		}
+	}
+
+	return
+}
+
+// ByEnvironment splits the dashboard into one dashboard per environment, in the order of environments.
+// Pipelines outside every environment are left out, and a pipeline may appear under many environments.
+func (dashboard Dashboard) ByEnvironment(environments []Environment) (grouped []EnvironmentDashboard) {
+	for _, environment := range environments {
+		grouped = append(grouped, EnvironmentDashboard{Environment: environment.Name, Dashboard: dashboard.ForEnvironment(environment)})
+	}
+
+	return
+}
@@ -0,0 +1,152 @@
+// environment_test.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/chiku/gocd"
+)
+
+func TestClientEnvironments(t *testing.T) {
+	const environmentsResponse = `{
+		"_embedded": {
+			"environments": [{
+				"name": "staging",
+				"pipelines": [{ "name": "Deploy Staging" }, { "name": "Smoke" }],
+				"environment_variables": [{ "name": "TARGET", "value": "staging", "secure": false }],
+				"origins": [{ "type": "gocd" }]
+			}, {
+				"name": "prod",
+				"pipelines": [{ "name": "Deploy Prod" }],
+				"environment_variables": [],
+				"origins": [{ "type": "gocd" }]
+			}]
+		}
+	}`
+	const agentsResponse = `{
+		"_embedded": {
+			"agents": [
+				{ "uuid": "agent-1", "environments": [{ "name": "staging", "origin": { "type": "gocd" } }] },
+				{ "uuid": "agent-2", "environments": [] }
+			]
+		}
+	}`
+	var accept string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/go/api/admin/environments":
+			accept = r.Header.Get("Accept")
+			w.Write([]byte(environmentsResponse))
+		case "/go/api/agents":
+			w.Write([]byte(agentsResponse))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	environments, err := client.Environments(ts.URL)
+
+	if err != nil {
+		t.Fatalf("Expected no error listing environments: %s", err)
+	}
+	if accept != "application/vnd.go.cd.v3+json" {
+		t.Errorf("Expected versioned accept header, but was: %s", accept)
+	}
+
+	expected := []gocd.Environment{{
+		Name:                 "staging",
+		Pipelines:            []string{"Deploy Staging", "Smoke"},
+		Agents:               []string{"agent-1"},
+		EnvironmentVariables: []gocd.EnvironmentVariable{{Name: "TARGET", Value: "staging"}},
+	}, {
+		Name:                 "prod",
+		Pipelines:            []string{"Deploy Prod"},
+		EnvironmentVariables: []gocd.EnvironmentVariable{},
+	}}
+	if !reflect.DeepEqual(environments, expected) {
+		t.Errorf("Expected proper environments (%#v != %#v)", environments, expected)
+	}
+}
+
+func TestClientEnvironmentsListingAgents(t *testing.T) {
+	const serverResponse = `{
+		"_embedded": {
+			"environments": [{
+				"name": "staging",
+				"pipelines": [{ "name": "Deploy Staging" }],
+				"agents": [{ "uuid": "agent-1" }],
+				"environment_variables": []
+			}, {
+				"name": "prod",
+				"pipelines": [{ "name": "Deploy Prod" }],
+				"agents": [],
+				"environment_variables": []
+			}]
+		}
+	}`
+	var paths []string
+	var accept string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path == "/go/api/version" {
+			w.Write([]byte(`{ "version": "19.0.0" }`))
+			return
+		}
+		accept = r.Header.Get("Accept")
+		w.Write([]byte(serverResponse))
+	}))
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	client.DetectServerVersion(ts.URL)
+	environments, err := client.Environments(ts.URL)
+
+	if err != nil {
+		t.Fatalf("Expected no error listing environments: %s", err)
+	}
+	if accept != "application/vnd.go.cd.v2+json" {
+		t.Errorf("Expected version 2 accept header, but was: %s", accept)
+	}
+	if !reflect.DeepEqual(paths, []string{"/go/api/version", "/go/api/admin/environments"}) {
+		t.Errorf("Expected agents to be read from the environments only, but requested: %v", paths)
+	}
+	if len(environments) != 2 || !reflect.DeepEqual(environments[0].Agents, []string{"agent-1"}) || len(environments[1].Agents) != 0 {
+		t.Errorf("Expected agents listed in the environments, but was: %#v", environments)
+	}
+}
+
+func TestDashboardByEnvironment(t *testing.T) {
+	s1 := []gocd.DashboardStage{{Name: "Deploy", Status: "Passed"}}
+	s2 := []gocd.DashboardStage{{Name: "Deploy", Status: "Failed"}}
+	s3 := []gocd.DashboardStage{{Name: "Build", Status: "Passed"}}
+	p1 := gocd.DashboardPipeline{Name: "Deploy Staging", Stages: s1}
+	p2 := gocd.DashboardPipeline{Name: "Deploy Prod", Stages: s2}
+	p3 := gocd.DashboardPipeline{Name: "Build", Stages: s3}
+	dashboard := gocd.Dashboard{p1, p2, p3}
+
+	environments := []gocd.Environment{
+		{Name: "prod", Pipelines: []string{"deploy prod"}},
+		{Name: "staging", Pipelines: []string{"Deploy Staging", "Missing"}},
+		{Name: "empty"},
+	}
+	grouped := dashboard.ByEnvironment(environments)
+
+	expected := []gocd.EnvironmentDashboard{
+		{Environment: "prod", Dashboard: gocd.Dashboard{p2}},
+		{Environment: "staging", Dashboard: gocd.Dashboard{p1}},
+		{Environment: "empty"},
+	}
+	if !reflect.DeepEqual(grouped, expected) {
+		t.Errorf("Expected dashboards per environment (%#v != %#v)", grouped, expected)
+	}
+}
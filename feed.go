@@ -0,0 +1,193 @@
+// feed.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	feedAccept   = "application/atom+xml, application/xml"
+	maxFeedPages = 20
+)
+
+// ErrFeedTruncated is returned with the entries read so far when StageFeedSince gave up following older pages
+// before reaching the entry last seen. Entries between those returned and the entry last seen are missing.
+var ErrFeedTruncated = errors.New("error fetching stage feed: the entry last seen was not within the pages read")
+
+var stageFeedTitle = regexp.MustCompile(`^(.+)\((\d+)\) stage (.+)\((\d+)\) (\S+)$`)
+
+type FeedPipeline struct {
+	Name      string
+	StagesURL string
+}
+
+// StageFeedEntry is a completed stage, as announced by the stage feed of a pipeline.
+type StageFeedEntry struct {
+	ID              string
+	Pipeline        string
+	PipelineCounter int
+	Stage           string
+	StageCounter    int
+	Result          string
+	Author          string
+	Updated         time.Time
+}
+
+// StageFeed is a page of stage completions, newest first. Next links to the page of older entries.
+type StageFeed struct {
+	Entries []StageFeedEntry
+	Next    string
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+type atomPipelines struct {
+	Pipelines []struct {
+		Href string `xml:"href,attr"`
+	} `xml:"pipeline"`
+}
+type atomFeed struct {
+	Links   []atomLink `xml:"link"`
+	Entries []struct {
+		ID      string   `xml:"id"`
+		Title   string   `xml:"title"`
+		Updated string   `xml:"updated"`
+		Authors []string `xml:"author>name"`
+	} `xml:"entry"`
+}
+
+// ParseStageFeed reads an Atom stage feed.
+func ParseStageFeed(body []byte) (StageFeed, error) {
+	var atom atomFeed
+	err := xml.Unmarshal(body, &atom)
+	if err != nil {
+		return StageFeed{}, fmt.Errorf("error unmarshalling Gocd feed: %s", err)
+	}
+
+	feed := StageFeed{}
+	for _, link := range atom.Links {
+		if link.Rel == "next" {
+			feed.Next = link.Href
+		}
+	}
+
+	for _, atomEntry := range atom.Entries {
+		matches := stageFeedTitle.FindStringSubmatch(strings.TrimSpace(atomEntry.Title))
+		if matches == nil {
+			return StageFeed{}, fmt.Errorf("error parsing Gocd feed entry: unexpected title %q", atomEntry.Title)
+		}
+
+		entry := StageFeedEntry{ID: strings.TrimSpace(atomEntry.ID), Pipeline: matches[1], Stage: matches[3], Result: matches[5]}
+		entry.PipelineCounter, _ = strconv.Atoi(matches[2])
+		entry.StageCounter, _ = strconv.Atoi(matches[4])
+		entry.Author = strings.TrimSpace(strings.Join(atomEntry.Authors, ", "))
+		entry.Updated, err = time.Parse(time.RFC3339, strings.TrimSpace(atomEntry.Updated))
+		if err != nil {
+			return StageFeed{}, fmt.Errorf("error parsing Gocd feed entry time: %s", err)
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	return feed, nil
+}
+
+// FeedPipelines lists the pipelines that publish a stage feed.
+func (c Client) FeedPipelines(server string) ([]FeedPipeline, error) {
+	body, err := c.feed(strings.TrimRight(server, "/") + "/go/api/feed/pipelines.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	var atom atomPipelines
+	err = xml.Unmarshal(body, &atom)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling Gocd feed: %s", err)
+	}
+
+	pipelines := []FeedPipeline{}
+	for _, pipeline := range atom.Pipelines {
+		link, err := url.Parse(pipeline.Href)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing Gocd feed link: %s", err)
+		}
+		name, _ := url.PathUnescape(path.Base(path.Dir(link.Path)))
+		pipelines = append(pipelines, FeedPipeline{Name: name, StagesURL: pipeline.Href})
+	}
+
+	return pipelines, nil
+}
+
+// StageFeed fetches the latest page of stage completions of a pipeline.
+func (c Client) StageFeed(server string, pipeline string) (StageFeed, error) {
+	body, err := c.feed(stageFeedURL(server, pipeline))
+	if err != nil {
+		return StageFeed{}, err
+	}
+
+	return ParseStageFeed(body)
+}
+
+// StageFeedSince fetches the stage completions of a pipeline newer than the entry with the given ID,
+// following older pages until that entry is found. Without an ID only the latest page is fetched.
+// Entries are newest first. When the entry is not found within 20 pages, the entries read are returned
+// with ErrFeedTruncated.
+func (c Client) StageFeedSince(server string, pipeline string, lastID string) ([]StageFeedEntry, error) {
+	var entries []StageFeedEntry
+	next := stageFeedURL(server, pipeline)
+
+	for page := 0; page < maxFeedPages && next != ""; page++ {
+		body, err := c.feed(next)
+		if err != nil {
+			return nil, err
+		}
+		feed, err := ParseStageFeed(body)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range feed.Entries {
+			if entry.ID == lastID {
+				return entries, nil
+			}
+			entries = append(entries, entry)
+		}
+
+		if lastID == "" {
+			return entries, nil
+		}
+		next = feed.Next
+	}
+
+	if next != "" {
+		return entries, ErrFeedTruncated
+	}
+	return entries, nil
+}
+
+func (c Client) feed(feedURL string) ([]byte, error) {
+	request, err := newGocdRequest("GET", feedURL, feedAccept, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.receiveNotHTML(request, "an Atom feed")
+}
+
+func stageFeedURL(server string, pipeline string) string {
+	return strings.TrimRight(server, "/") + "/go/api/pipelines/" + url.PathEscape(pipeline) + "/stages.xml"
+}
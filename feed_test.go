@@ -0,0 +1,196 @@
+// feed_test.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chiku/gocd"
+)
+
+func stageFeedEntryXML(counter int, stage string, result string) string {
+	return fmt.Sprintf(`
+	<entry>
+		<title><![CDATA[Build(%d) stage %s(1) %s]]></title>
+		<updated>2017-07-14T02:%02d:00+00:00</updated>
+		<id>http://gocd/go/pipelines/Build/%d/%s/1</id>
+		<author><name><![CDATA[dev <dev@example.com>]]></name></author>
+		<category scheme="http://www.thoughtworks-studios.com/ns/categories/go" term="stage" label="Stage" />
+	</entry>`, counter, stage, result, counter, counter, stage)
+}
+
+func stageFeedXML(next string, entries ...string) string {
+	link := ""
+	if next != "" {
+		link = `<link rel="next" href="` + next + `"/>`
+	}
+	return `<?xml version="1.0" encoding="UTF-8"?>
+	<feed xmlns="http://www.w3.org/2005/Atom" xmlns:go="http://www.thoughtworks-studios.com/ns/go">
+		<title><![CDATA[Build]]></title>
+		<link rel="self" href="http://gocd/go/api/pipelines/Build/stages.xml"/>
+		` + link + strings.Join(entries, "") + `
+	</feed>`
+}
+
+func fakeFeedServer(requests *[]string) *httptest.Server {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r.URL.RequestURI())
+		switch r.URL.RequestURI() {
+		case "/go/api/feed/pipelines.xml":
+			w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+			<pipelines xmlns="http://www.w3.org/2005/Atom">
+				<link rel="self" href="` + ts.URL + `/go/api/feed/pipelines.xml"/>
+				<pipeline href="` + ts.URL + `/go/api/pipelines/Build/stages.xml"/>
+				<pipeline href="` + ts.URL + `/go/api/pipelines/Deploy%20Prod/stages.xml"/>
+			</pipelines>`))
+		case "/go/api/pipelines/Build/stages.xml":
+			w.Write([]byte(stageFeedXML(ts.URL+"/go/api/pipelines/Build/stages.xml?before=3", stageFeedEntryXML(5, "Test", "Failed"), stageFeedEntryXML(4, "Test", "Passed"))))
+		case "/go/api/pipelines/Build/stages.xml?before=3":
+			w.Write([]byte(stageFeedXML("", stageFeedEntryXML(3, "Compile", "Passed"), stageFeedEntryXML(2, "Compile", "Cancelled"))))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return ts
+}
+
+func TestClientFeedPipelines(t *testing.T) {
+	var requests []string
+	ts := fakeFeedServer(&requests)
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	pipelines, err := client.FeedPipelines(ts.URL)
+
+	if err != nil {
+		t.Fatalf("Expected no error fetching feed pipelines: %s", err)
+	}
+
+	expected := []gocd.FeedPipeline{
+		{Name: "Build", StagesURL: ts.URL + "/go/api/pipelines/Build/stages.xml"},
+		{Name: "Deploy Prod", StagesURL: ts.URL + "/go/api/pipelines/Deploy%20Prod/stages.xml"},
+	}
+	if !reflect.DeepEqual(pipelines, expected) {
+		t.Errorf("Expected pipelines with stage feeds (%#v != %#v)", pipelines, expected)
+	}
+}
+
+func TestClientStageFeed(t *testing.T) {
+	var requests []string
+	ts := fakeFeedServer(&requests)
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	feed, err := client.StageFeed(ts.URL, "Build")
+
+	if err != nil {
+		t.Fatalf("Expected no error fetching stage feed: %s", err)
+	}
+	if feed.Next != ts.URL+"/go/api/pipelines/Build/stages.xml?before=3" || len(feed.Entries) != 2 {
+		t.Fatalf("Expected a page of 2 entries with a link to older entries, but was: %#v", feed)
+	}
+
+	expected := gocd.StageFeedEntry{
+		ID:              "http://gocd/go/pipelines/Build/5/Test/1",
+		Pipeline:        "Build",
+		PipelineCounter: 5,
+		Stage:           "Test",
+		StageCounter:    1,
+		Result:          "Failed",
+		Author:          "dev <dev@example.com>",
+		Updated:         time.Date(2017, time.July, 14, 2, 5, 0, 0, time.UTC),
+	}
+	entry := feed.Entries[0]
+	if entry.ID != expected.ID || entry.Pipeline != expected.Pipeline || entry.PipelineCounter != expected.PipelineCounter ||
+		entry.Stage != expected.Stage || entry.StageCounter != expected.StageCounter || entry.Result != expected.Result ||
+		entry.Author != expected.Author || !entry.Updated.Equal(expected.Updated) {
+		t.Errorf("Expected proper feed entry (%#v != %#v)", entry, expected)
+	}
+}
+
+func TestClientStageFeedSince(t *testing.T) {
+	var requests []string
+	ts := fakeFeedServer(&requests)
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	entries, err := client.StageFeedSince(ts.URL, "Build", "http://gocd/go/pipelines/Build/3/Compile/1")
+
+	if err != nil {
+		t.Fatalf("Expected no error fetching stage feed: %s", err)
+	}
+
+	var counters []int
+	for _, entry := range entries {
+		counters = append(counters, entry.PipelineCounter)
+	}
+	if !reflect.DeepEqual(counters, []int{5, 4}) {
+		t.Errorf("Expected entries newer than the last seen entry, but was: %v", counters)
+	}
+	if len(requests) != 2 {
+		t.Errorf("Expected to follow the feed to the last seen entry, but requested: %v", requests)
+	}
+}
+
+func TestClientStageFeedSinceWithoutLastEntry(t *testing.T) {
+	var requests []string
+	ts := fakeFeedServer(&requests)
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	entries, err := client.StageFeedSince(ts.URL, "Build", "")
+
+	if err != nil {
+		t.Fatalf("Expected no error fetching stage feed: %s", err)
+	}
+	if len(entries) != 2 || len(requests) != 1 {
+		t.Errorf("Expected only the latest page, but was: %#v (requested: %v)", entries, requests)
+	}
+}
+
+func TestClientStageFeedSinceBeyondPageLimit(t *testing.T) {
+	requests := 0
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		counter := 50 - requests
+		next := fmt.Sprintf("%s/go/api/pipelines/Build/stages.xml?before=%d", ts.URL, counter)
+		w.Write([]byte(stageFeedXML(next, stageFeedEntryXML(counter, "Test", "Passed"))))
+	}))
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	entries, err := client.StageFeedSince(ts.URL, "Build", "http://gocd/go/pipelines/Build/3/Compile/1")
+
+	if err != gocd.ErrFeedTruncated {
+		t.Errorf("Expected error for a truncated feed, but was: %v", err)
+	}
+	if len(entries) != 20 || requests != 20 || entries[19].PipelineCounter != 30 {
+		t.Errorf("Expected the entries of 20 pages, but was: %d entries (requested: %d)", len(entries), requests)
+	}
+}
+
+func TestParseStageFeedOnError(t *testing.T) {
+	_, err := gocd.ParseStageFeed([]byte(`<feed><entry><title>Unexpected</title></entry></feed>`))
+
+	if err == nil || err.Error() != `error parsing Gocd feed entry: unexpected title "Unexpected"` {
+		t.Errorf("Expected error about unexpected title, but was: %v", err)
+	}
+
+	_, err = gocd.ParseStageFeed([]byte(`Random`))
+
+	if err == nil || !strings.Contains(err.Error(), "error unmarshalling Gocd feed: ") {
+		t.Errorf("Expected error about XML unmarshall error, but was: %v", err)
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/chiku/gocd
+
+go 1.21
@@ -72,7 +72,7 @@ func TestFetchWhenServerError(t *testing.T) {
 		t.Fatalf("Expected error fetching invalid response: %s", err)
 	}
 
-	if err.Error() != "error fetching response from Gocd: the HTTP status code was 403, body: Forbidden!" {
+	if err.Error() != "error authenticating with Gocd at "+ts.URL+": the HTTP status code was 403, body: Forbidden!" {
 		t.Errorf("Expected proper error message but was: %s", err.Error())
 	}
 
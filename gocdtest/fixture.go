@@ -0,0 +1,150 @@
+// fixture.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocdtest
+
+import (
+	"encoding/json"
+
+	"github.com/chiku/gocd"
+)
+
+// Fixture describes pipeline groups for tests, one call per group, pipeline and instance:
+//
+//	gocdtest.NewFixture().
+//		Group("Group").
+//		Pipeline("Build").PreviousResult("Failed").
+//		Instance(gocdtest.Passed("Compile"), gocdtest.Failed("Test")).
+//		Instance(gocdtest.Building("Compile"), gocdtest.Unknown("Test")).
+//		PipelineGroups()
+//
+// Pipelines go in the group described last, and instances, oldest first, in the pipeline described last.
+// Describing a pipeline before any group, or an instance before any pipeline, panics.
+type Fixture struct {
+	groups []*group
+}
+
+// NewFixture starts describing pipeline groups.
+func NewFixture() *Fixture {
+	return &Fixture{}
+}
+
+// Group adds a group.
+func (fixture *Fixture) Group(name string) *Fixture {
+	fixture.groups = append(fixture.groups, &group{name: name})
+	return fixture
+}
+
+// Pipeline adds a pipeline without instances to the last group.
+func (fixture *Fixture) Pipeline(name string) *Fixture {
+	if len(fixture.groups) == 0 {
+		panic("gocdtest: pipeline " + name + " described before any group")
+	}
+
+	group := fixture.groups[len(fixture.groups)-1]
+	group.pipelines = append(group.pipelines, &pipeline{name: name})
+	return fixture
+}
+
+// PreviousResult sets the result of the instance before those described for the last pipeline.
+func (fixture *Fixture) PreviousResult(result string) *Fixture {
+	fixture.last("previous result").previousResult = result
+	return fixture
+}
+
+// Instance adds an instance with the stages given to the last pipeline, as its latest instance.
+func (fixture *Fixture) Instance(stages ...gocd.Stage) *Fixture {
+	pipeline := fixture.last("instance")
+	pipeline.instances = append(pipeline.instances, gocd.Instance{Stages: append([]gocd.Stage{}, stages...)})
+	return fixture
+}
+
+// PipelineGroups returns the groups described, as read from the legacy dashboard.
+func (fixture *Fixture) PipelineGroups() gocd.PipelineGroups {
+	return pipelineGroups(fixture.groups)
+}
+
+// JSON returns the legacy dashboard JSON Gocd serves for the groups described.
+func (fixture *Fixture) JSON() []byte {
+	body, _ := json.Marshal(legacyDashboard(fixture.groups))
+	return body
+}
+
+// HALJSON returns the dashboard API JSON Gocd serves for the groups described.
+// It carries no previous results.
+func (fixture *Fixture) HALJSON() []byte {
+	body, _ := json.Marshal(halDashboard(fixture.groups))
+	return body
+}
+
+func (fixture *Fixture) last(described string) *pipeline {
+	if len(fixture.groups) > 0 {
+		pipelines := fixture.groups[len(fixture.groups)-1].pipelines
+		if len(pipelines) > 0 {
+			return pipelines[len(pipelines)-1]
+		}
+	}
+
+	panic("gocdtest: " + described + " described before any pipeline")
+}
+
+// Passed is a stage that passed.
+func Passed(name string) gocd.Stage {
+	return gocd.Stage{Name: name, Status: "Passed"}
+}
+
+// Failed is a stage that failed.
+func Failed(name string) gocd.Stage {
+	return gocd.Stage{Name: name, Status: "Failed"}
+}
+
+// Building is a stage that is running.
+func Building(name string) gocd.Stage {
+	return gocd.Stage{Name: name, Status: "Building"}
+}
+
+// Cancelled is a stage that was cancelled.
+func Cancelled(name string) gocd.Stage {
+	return gocd.Stage{Name: name, Status: "Cancelled"}
+}
+
+// Unknown is a stage that has not run in its instance.
+func Unknown(name string) gocd.Stage {
+	return gocd.Stage{Name: name, Status: "Unknown"}
+}
+
+func pipelineGroups(groups []*group) gocd.PipelineGroups {
+	pipelineGroups := gocd.PipelineGroups{}
+	for _, group := range groups {
+		pipelineGroup := gocd.PipelineGroup{Pipelines: []gocd.Pipeline{}}
+		for _, pipeline := range group.pipelines {
+			pipelineGroup.Pipelines = append(pipelineGroup.Pipelines, gocd.Pipeline{
+				Name:             pipeline.name,
+				Instances:        copyInstances(pipeline.instances),
+				PreviousInstance: gocd.PreviousInstance{Result: pipeline.previousResult},
+			})
+		}
+		pipelineGroups = append(pipelineGroups, pipelineGroup)
+	}
+
+	return pipelineGroups
+}
+
+func copyGroups(groups []*group) []*group {
+	copies := []*group{}
+	for _, group := range groups {
+		groupCopy := *group
+		groupCopy.pipelines = nil
+		for _, pipeline := range group.pipelines {
+			pipelineCopy := *pipeline
+			pipelineCopy.instances = copyInstances(pipeline.instances)
+			groupCopy.pipelines = append(groupCopy.pipelines, &pipelineCopy)
+		}
+		copies = append(copies, &groupCopy)
+	}
+
+	return copies
+}
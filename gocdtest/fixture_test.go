@@ -0,0 +1,128 @@
+// fixture_test.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocdtest_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/chiku/gocd"
+	"github.com/chiku/gocd/gocdtest"
+)
+
+func newFixture() *gocdtest.Fixture {
+	return gocdtest.NewFixture().
+		Group("Group").
+		Pipeline("Build").PreviousResult("Failed").
+		Instance(gocdtest.Passed("Compile"), gocdtest.Failed("Test")).
+		Instance(gocdtest.Building("Compile"), gocdtest.Unknown("Test")).
+		Pipeline("Deploy").
+		Group("Other").
+		Pipeline("Smoke").
+		Instance(gocdtest.Cancelled("Run"))
+}
+
+func TestFixturePipelineGroups(t *testing.T) {
+	groups := newFixture().PipelineGroups()
+
+	expected := gocd.PipelineGroups{
+		{Pipelines: []gocd.Pipeline{
+			{
+				Name: "Build",
+				Instances: []gocd.Instance{
+					{Stages: []gocd.Stage{{Name: "Compile", Status: "Passed"}, {Name: "Test", Status: "Failed"}}},
+					{Stages: []gocd.Stage{{Name: "Compile", Status: "Building"}, {Name: "Test", Status: "Unknown"}}},
+				},
+				PreviousInstance: gocd.PreviousInstance{Result: "Failed"},
+			},
+			{Name: "Deploy", Instances: []gocd.Instance{}},
+		}},
+		{Pipelines: []gocd.Pipeline{
+			{Name: "Smoke", Instances: []gocd.Instance{{Stages: []gocd.Stage{{Name: "Run", Status: "Cancelled"}}}}},
+		}},
+	}
+	if !reflect.DeepEqual(groups, expected) {
+		t.Errorf("Expected pipeline groups to be %+v, but was: %+v", expected, groups)
+	}
+}
+
+func TestFixtureJSON(t *testing.T) {
+	fixture := newFixture()
+
+	groups, err := gocd.NewPipelineGroups(fixture.JSON())
+	if err != nil {
+		t.Fatalf("Expected no error reading the fixture JSON, but was: %s", err)
+	}
+
+	if !reflect.DeepEqual(groups, fixture.PipelineGroups()) {
+		t.Errorf("Expected JSON to read as the pipeline groups, but was: %+v", groups)
+	}
+}
+
+func TestFixtureHALJSON(t *testing.T) {
+	fixture := gocdtest.NewFixture().
+		Group("Group").
+		Pipeline("Build").
+		Instance(gocdtest.Passed("Compile")).
+		Instance(gocdtest.Unknown("Compile"))
+
+	groups, err := gocd.NewPipelineGroupsFromHAL(fixture.HALJSON())
+	if err != nil {
+		t.Fatalf("Expected no error reading the fixture HAL JSON, but was: %s", err)
+	}
+
+	dashboard := groups.ToDashboard()
+	expected := gocd.Dashboard{{Name: "Build", Stages: []gocd.DashboardStage{{Name: "Compile", Status: "Passed"}}}}
+	if !reflect.DeepEqual(dashboard, expected) {
+		t.Errorf("Expected dashboard to be %+v, but was: %+v", expected, dashboard)
+	}
+}
+
+func TestFixtureIsLoadedIntoServer(t *testing.T) {
+	server := gocdtest.NewServer()
+	defer server.Close()
+
+	fixture := newFixture()
+	server.Load(fixture)
+	fixture.Pipeline("Later")
+
+	if !reflect.DeepEqual(server.PipelineGroups(), newFixture().PipelineGroups()) {
+		t.Errorf("Expected server to hold the fixture as loaded, but was: %+v", server.PipelineGroups())
+	}
+
+	dashboard, err := gocd.NewClient().Fetch(server.LegacyDashboardURL())
+	if err != nil {
+		t.Fatalf("Expected no error fetching the dashboard, but was: %s", err)
+	}
+	expected := gocd.Dashboard{
+		{Name: "Build", Stages: []gocd.DashboardStage{{Name: "Compile", Status: "Recovering"}, {Name: "Test", Status: "Failed"}}},
+		{Name: "Smoke", Stages: []gocd.DashboardStage{{Name: "Run", Status: "Cancelled"}}},
+	}
+	if !reflect.DeepEqual(dashboard, expected) {
+		t.Errorf("Expected dashboard to be %+v, but was: %+v", expected, dashboard)
+	}
+}
+
+func TestFixturePanicsWithoutPipeline(t *testing.T) {
+	defer func() {
+		if recovered := recover(); recovered != "gocdtest: instance described before any pipeline" {
+			t.Errorf("Expected panic describing an instance before any pipeline, but was: %v", recovered)
+		}
+	}()
+
+	gocdtest.NewFixture().Group("Group").Instance(gocdtest.Passed("Compile"))
+}
+
+func TestFixturePanicsWithoutGroup(t *testing.T) {
+	defer func() {
+		if recovered := recover(); recovered != "gocdtest: pipeline Build described before any group" {
+			t.Errorf("Expected panic describing a pipeline before any group, but was: %v", recovered)
+		}
+	}()
+
+	gocdtest.NewFixture().Pipeline("Build")
+}
@@ -0,0 +1,357 @@
+// server.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+// Package gocdtest provides a fake Gocd server for testing code that uses the gocd package.
+package gocdtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+
+	"github.com/chiku/gocd"
+)
+
+const (
+	// LegacyDashboardPath serves the dashboard in the format of older Gocd servers.
+	LegacyDashboardPath = "/go/dashboard.json"
+	// DashboardPath serves the dashboard API, in HAL.
+	DashboardPath = "/go/api/dashboard"
+	// VersionPath serves the version of the fake server.
+	VersionPath = "/go/api/version"
+
+	halContentType = "application/vnd.go.cd.v3+json; charset=utf-8"
+)
+
+type pipeline struct {
+	name           string
+	instances      []gocd.Instance
+	previousResult string
+}
+type group struct {
+	name      string
+	pipelines []*pipeline
+}
+
+// Server is a fake Gocd server holding its groups, pipelines, instances and stage statuses in memory.
+// It serves the legacy dashboard, the dashboard API and the version API, and is safe for concurrent use.
+type Server struct {
+	*httptest.Server
+
+	mutex   sync.Mutex
+	version string
+	groups  []*group
+	failure int
+}
+
+// NewServer starts a fake Gocd server without pipelines. Close it when done.
+func NewServer() *Server {
+	server := &Server{version: "18.3.0"}
+	mux := http.NewServeMux()
+	mux.HandleFunc(LegacyDashboardPath, server.serveLegacyDashboard)
+	mux.HandleFunc(DashboardPath, server.serveDashboard)
+	mux.HandleFunc(VersionPath, server.serveVersion)
+	server.Server = httptest.NewServer(mux)
+
+	return server
+}
+
+// LegacyDashboardURL is where the fake server serves the legacy dashboard JSON.
+func (server *Server) LegacyDashboardURL() string {
+	return server.URL + LegacyDashboardPath
+}
+
+// DashboardURL is where the fake server serves the dashboard API.
+func (server *Server) DashboardURL() string {
+	return server.URL + DashboardPath
+}
+
+// SetVersion changes the release reported by the version API.
+func (server *Server) SetVersion(version string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.version = version
+}
+
+// Fail makes every request answer with the HTTP status code given, until called with 0.
+func (server *Server) Fail(statusCode int) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.failure = statusCode
+}
+
+// Load replaces the groups, pipelines and instances of the fake server with those described by fixture.
+func (server *Server) Load(fixture *Fixture) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.groups = copyGroups(fixture.groups)
+}
+
+// AddPipeline adds a pipeline without instances to a group, creating the group when it is new.
+func (server *Server) AddPipeline(groupName string, name string) error {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if server.find(name) != nil {
+		return fmt.Errorf("error adding pipeline %s: it already exists", name)
+	}
+
+	var target *group
+	for _, group := range server.groups {
+		if group.name == groupName {
+			target = group
+		}
+	}
+	if target == nil {
+		target = &group{name: groupName}
+		server.groups = append(server.groups, target)
+	}
+	target.pipelines = append(target.pipelines, &pipeline{name: name})
+
+	return nil
+}
+
+// RemovePipeline removes a pipeline, and its group when that is left empty.
+func (server *Server) RemovePipeline(name string) error {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	for i, group := range server.groups {
+		for j, pipeline := range group.pipelines {
+			if pipeline.name != name {
+				continue
+			}
+			group.pipelines = append(group.pipelines[:j], group.pipelines[j+1:]...)
+			if len(group.pipelines) == 0 {
+				server.groups = append(server.groups[:i], server.groups[i+1:]...)
+			}
+			return nil
+		}
+	}
+
+	return fmt.Errorf("error removing pipeline %s: no such pipeline", name)
+}
+
+// AddInstance runs a pipeline again with the stages given, making it the latest instance.
+// It returns the counter of the new instance.
+func (server *Server) AddInstance(name string, stages ...gocd.Stage) (int, error) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	pipeline := server.find(name)
+	if pipeline == nil {
+		return 0, fmt.Errorf("error adding instance to pipeline %s: no such pipeline", name)
+	}
+
+	pipeline.instances = append(pipeline.instances, gocd.Instance{Stages: append([]gocd.Stage{}, stages...)})
+	return len(pipeline.instances), nil
+}
+
+// SetStageStatus changes the status of a stage in the latest instance of a pipeline.
+func (server *Server) SetStageStatus(name string, stage string, status string) error {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	pipeline := server.find(name)
+	if pipeline == nil || len(pipeline.instances) == 0 {
+		return fmt.Errorf("error setting status of stage %s: pipeline %s has no instance", stage, name)
+	}
+
+	stages := pipeline.instances[len(pipeline.instances)-1].Stages
+	for i := range stages {
+		if stages[i].Name == stage {
+			stages[i].Status = status
+			return nil
+		}
+	}
+
+	return fmt.Errorf("error setting status of stage %s: no such stage in pipeline %s", stage, name)
+}
+
+// SetPreviousResult changes the result of the instance before those served in the legacy dashboard.
+// The dashboard API carries no previous result.
+func (server *Server) SetPreviousResult(name string, result string) error {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	pipeline := server.find(name)
+	if pipeline == nil {
+		return fmt.Errorf("error setting previous result: no pipeline %s", name)
+	}
+
+	pipeline.previousResult = result
+	return nil
+}
+
+// PipelineGroups returns the current state of the fake server, as the legacy dashboard reads.
+func (server *Server) PipelineGroups() gocd.PipelineGroups {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	return pipelineGroups(server.groups)
+}
+
+func (server *Server) find(name string) *pipeline {
+	for _, group := range server.groups {
+		for _, pipeline := range group.pipelines {
+			if pipeline.name == name {
+				return pipeline
+			}
+		}
+	}
+
+	return nil
+}
+
+// failed answers the request with the failure set, if any.
+func (server *Server) failed(w http.ResponseWriter) bool {
+	server.mutex.Lock()
+	failure := server.failure
+	server.mutex.Unlock()
+
+	if failure == 0 {
+		return false
+	}
+	http.Error(w, http.StatusText(failure), failure)
+	return true
+}
+
+func (server *Server) serveLegacyDashboard(w http.ResponseWriter, r *http.Request) {
+	if server.failed(w) {
+		return
+	}
+
+	server.mutex.Lock()
+	body := legacyDashboard(server.groups)
+	server.mutex.Unlock()
+
+	writeJSON(w, "application/json; charset=utf-8", body)
+}
+
+func (server *Server) serveDashboard(w http.ResponseWriter, r *http.Request) {
+	if server.failed(w) {
+		return
+	}
+
+	server.mutex.Lock()
+	body := halDashboard(server.groups)
+	server.mutex.Unlock()
+
+	writeJSON(w, halContentType, body)
+}
+
+func (server *Server) serveVersion(w http.ResponseWriter, r *http.Request) {
+	if server.failed(w) {
+		return
+	}
+
+	server.mutex.Lock()
+	body := gocd.ServerVersion{Version: server.version, FullVersion: server.version}
+	server.mutex.Unlock()
+
+	writeJSON(w, "application/vnd.go.cd.v1+json; charset=utf-8", body)
+}
+
+func writeJSON(w http.ResponseWriter, contentType string, body interface{}) {
+	output, err := json.Marshal(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Write(output)
+}
+
+func legacyDashboard(groups []*group) interface{} {
+	type legacyPipeline struct {
+		Name             string                `json:"name"`
+		Instances        []gocd.Instance       `json:"instances"`
+		PreviousInstance gocd.PreviousInstance `json:"previous_instance"`
+	}
+	type legacyGroup struct {
+		Name      string           `json:"name"`
+		Pipelines []legacyPipeline `json:"pipelines"`
+	}
+
+	body := []legacyGroup{}
+	for _, group := range groups {
+		legacy := legacyGroup{Name: group.name, Pipelines: []legacyPipeline{}}
+		for _, pipeline := range group.pipelines {
+			legacy.Pipelines = append(legacy.Pipelines, legacyPipeline{
+				Name:             pipeline.name,
+				Instances:        copyInstances(pipeline.instances),
+				PreviousInstance: gocd.PreviousInstance{Result: pipeline.previousResult},
+			})
+		}
+		body = append(body, legacy)
+	}
+
+	return body
+}
+
+// halDashboard lists pipelines apart from their groups, with the newest instance first, as the dashboard API does.
+func halDashboard(groups []*group) interface{} {
+	type halGroup struct {
+		Name      string   `json:"name"`
+		Pipelines []string `json:"pipelines"`
+	}
+	type halStages struct {
+		Stages []gocd.Stage `json:"stages"`
+	}
+	type halInstance struct {
+		Label    string    `json:"label"`
+		Counter  int       `json:"counter"`
+		Embedded halStages `json:"_embedded"`
+	}
+	type halInstances struct {
+		Instances []halInstance `json:"instances"`
+	}
+	type halPipeline struct {
+		Name     string       `json:"name"`
+		Embedded halInstances `json:"_embedded"`
+	}
+	type halEmbedded struct {
+		PipelineGroups []halGroup    `json:"pipeline_groups"`
+		Pipelines      []halPipeline `json:"pipelines"`
+	}
+
+	embedded := halEmbedded{PipelineGroups: []halGroup{}, Pipelines: []halPipeline{}}
+	for _, group := range groups {
+		hal := halGroup{Name: group.name, Pipelines: []string{}}
+		for _, pipeline := range group.pipelines {
+			hal.Pipelines = append(hal.Pipelines, pipeline.name)
+
+			instances := []halInstance{}
+			for i := len(pipeline.instances) - 1; i >= 0; i-- {
+				counter := i + 1
+				stages := append([]gocd.Stage{}, pipeline.instances[i].Stages...)
+				instances = append(instances, halInstance{Label: strconv.Itoa(counter), Counter: counter, Embedded: halStages{Stages: stages}})
+			}
+			embedded.Pipelines = append(embedded.Pipelines, halPipeline{Name: pipeline.name, Embedded: halInstances{Instances: instances}})
+		}
+		embedded.PipelineGroups = append(embedded.PipelineGroups, hal)
+	}
+
+	return struct {
+		Embedded halEmbedded `json:"_embedded"`
+	}{embedded}
+}
+
+func copyInstances(instances []gocd.Instance) []gocd.Instance {
+	copies := []gocd.Instance{}
+	for _, instance := range instances {
+		copies = append(copies, gocd.Instance{Stages: append([]gocd.Stage{}, instance.Stages...)})
+	}
+
+	return copies
+}
@@ -0,0 +1,176 @@
+// server_test.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocdtest_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chiku/gocd"
+	"github.com/chiku/gocd/gocdtest"
+)
+
+func newFakeServer(t *testing.T) *gocdtest.Server {
+	server := gocdtest.NewServer()
+	t.Cleanup(server.Close)
+
+	server.AddPipeline("Group", "Build")
+	server.AddPipeline("Group", "Deploy")
+	server.AddPipeline("Other", "Smoke")
+	server.AddInstance("Build", gocd.Stage{Name: "Compile", Status: "Passed"}, gocd.Stage{Name: "Test", Status: "Failed"})
+	server.AddInstance("Build", gocd.Stage{Name: "Compile", Status: "Building"}, gocd.Stage{Name: "Test", Status: "Unknown"})
+	server.AddInstance("Deploy", gocd.Stage{Name: "Release", Status: "Passed"})
+
+	return server
+}
+
+func dashboardOf(t *testing.T, fetcher gocd.DashboardFetcher, url string) gocd.Dashboard {
+	dashboard, err := fetcher.Fetch(url)
+	if err != nil {
+		t.Fatalf("Expected no error fetching the dashboard, but was: %s", err)
+	}
+
+	return dashboard
+}
+
+func TestServerServesTheLegacyDashboard(t *testing.T) {
+	server := newFakeServer(t)
+
+	dashboard := dashboardOf(t, gocd.NewClient(), server.LegacyDashboardURL())
+
+	expected := gocd.Dashboard{
+		{Name: "Build", Stages: []gocd.DashboardStage{{Name: "Compile", Status: "Building"}, {Name: "Test", Status: "Failed"}}},
+		{Name: "Deploy", Stages: []gocd.DashboardStage{{Name: "Release", Status: "Passed"}}},
+	}
+	if !reflect.DeepEqual(dashboard, expected) {
+		t.Errorf("Expected dashboard to be %+v, but was: %+v", expected, dashboard)
+	}
+}
+
+func TestServerServesTheDashboardAPI(t *testing.T) {
+	server := newFakeServer(t)
+
+	dashboard := dashboardOf(t, gocd.NewClient(), server.DashboardURL())
+
+	expected := gocd.Dashboard{
+		{Name: "Build", Stages: []gocd.DashboardStage{{Name: "Compile", Status: "Building"}, {Name: "Test", Status: "Failed"}}},
+		{Name: "Deploy", Stages: []gocd.DashboardStage{{Name: "Release", Status: "Passed"}}},
+	}
+	if !reflect.DeepEqual(dashboard, expected) {
+		t.Errorf("Expected dashboard to be %+v, but was: %+v", expected, dashboard)
+	}
+}
+
+func TestServerServesBothFormatsAlike(t *testing.T) {
+	server := newFakeServer(t)
+	client := gocd.NewClient()
+
+	legacy := dashboardOf(t, client, server.LegacyDashboardURL())
+	hal := dashboardOf(t, client, server.DashboardURL())
+
+	if !reflect.DeepEqual(legacy, hal) {
+		t.Errorf("Expected both formats to give the same dashboard, but were: %+v and %+v", legacy, hal)
+	}
+}
+
+func TestServerReflectsChanges(t *testing.T) {
+	server := newFakeServer(t)
+	client := gocd.NewClient()
+
+	err := server.SetStageStatus("Build", "Compile", "Passed")
+	if err != nil {
+		t.Fatalf("Expected no error setting a stage status, but was: %s", err)
+	}
+	server.SetPreviousResult("Deploy", "Failed")
+	server.AddInstance("Deploy", gocd.Stage{Name: "Release", Status: "Building"})
+	server.RemovePipeline("Build")
+
+	dashboard := dashboardOf(t, client, server.LegacyDashboardURL())
+
+	expected := gocd.Dashboard{{Name: "Deploy", Stages: []gocd.DashboardStage{{Name: "Release", Status: "Recovering"}}}}
+	if !reflect.DeepEqual(dashboard, expected) {
+		t.Errorf("Expected dashboard to be %+v, but was: %+v", expected, dashboard)
+	}
+}
+
+func TestServerPipelineGroups(t *testing.T) {
+	server := newFakeServer(t)
+	server.SetStageStatus("Build", "Test", "Passed")
+
+	groups := server.PipelineGroups()
+
+	if len(groups) != 2 {
+		t.Fatalf("Expected 2 pipeline groups, but was: %d", len(groups))
+	}
+	build := groups[0].Pipelines[0]
+	if len(build.Instances) != 2 {
+		t.Fatalf("Expected 2 instances of Build, but was: %d", len(build.Instances))
+	}
+	if build.Instances[1].Stages[1].Status != "Passed" {
+		t.Errorf("Expected status of Test in the latest instance to be Passed, but was: %s", build.Instances[1].Stages[1].Status)
+	}
+	if build.Instances[0].Stages[1].Status != "Failed" {
+		t.Errorf("Expected status of Test in the older instance to be unchanged, but was: %s", build.Instances[0].Stages[1].Status)
+	}
+}
+
+func TestServerRejectsUnknownPipelines(t *testing.T) {
+	server := newFakeServer(t)
+
+	if err := server.AddPipeline("Group", "Build"); err == nil || err.Error() != "error adding pipeline Build: it already exists" {
+		t.Errorf("Expected error adding an existing pipeline, but was: %v", err)
+	}
+	if _, err := server.AddInstance("Missing"); err == nil || err.Error() != "error adding instance to pipeline Missing: no such pipeline" {
+		t.Errorf("Expected error adding an instance to a missing pipeline, but was: %v", err)
+	}
+	if err := server.SetStageStatus("Smoke", "Run", "Passed"); err == nil || err.Error() != "error setting status of stage Run: pipeline Smoke has no instance" {
+		t.Errorf("Expected error setting the status of a pipeline without instances, but was: %v", err)
+	}
+	if err := server.SetStageStatus("Build", "Missing", "Passed"); err == nil || err.Error() != "error setting status of stage Missing: no such stage in pipeline Build" {
+		t.Errorf("Expected error setting the status of a missing stage, but was: %v", err)
+	}
+	if err := server.RemovePipeline("Missing"); err == nil || err.Error() != "error removing pipeline Missing: no such pipeline" {
+		t.Errorf("Expected error removing a missing pipeline, but was: %v", err)
+	}
+}
+
+func TestServerVersionSelectsTheDashboard(t *testing.T) {
+	server := newFakeServer(t)
+	server.SetVersion("17.3.0")
+
+	client := gocd.NewClient()
+	version, err := client.DetectServerVersion(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error detecting the server version, but was: %s", err)
+	}
+	if version.Version != "17.3.0" {
+		t.Errorf("Expected version to be 17.3.0, but was: %s", version.Version)
+	}
+
+	dashboard, err := client.FetchDashboard(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error fetching the dashboard, but was: %s", err)
+	}
+	if len(dashboard) != 2 {
+		t.Errorf("Expected 2 pipelines on the dashboard, but was: %d", len(dashboard))
+	}
+}
+
+func TestServerFail(t *testing.T) {
+	server := newFakeServer(t)
+	client := gocd.NewClient()
+
+	server.Fail(500)
+	_, err := client.Fetch(server.DashboardURL())
+	if err == nil || !strings.Contains(err.Error(), "the HTTP status code was 500") {
+		t.Errorf("Expected error with the status code, but was: %v", err)
+	}
+
+	server.Fail(0)
+	dashboardOf(t, client, server.DashboardURL())
+}
@@ -0,0 +1,108 @@
+// history.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type HistoryJob struct {
+	Name          string `json:"name"`
+	State         string `json:"state"`
+	Result        string `json:"result"`
+	ScheduledDate int64  `json:"scheduled_date"`
+}
+type HistoryStage struct {
+	Name           string       `json:"name"`
+	Counter        int          `json:"counter,string"`
+	Result         string       `json:"result"`
+	ApprovedBy     string       `json:"approved_by"`
+	Scheduled      bool         `json:"scheduled"`
+	RerunOfCounter *int         `json:"rerun_of_counter"`
+	Jobs           []HistoryJob `json:"jobs"`
+}
+type PipelineRun struct {
+	Name       string         `json:"name"`
+	Counter    int            `json:"counter"`
+	Label      string         `json:"label"`
+	BuildCause BuildCause     `json:"build_cause"`
+	Stages     []HistoryStage `json:"stages"`
+}
+type PipelineHistory struct {
+	Pipelines  []PipelineRun `json:"pipelines"`
+	Pagination Pagination    `json:"pagination"`
+}
+type StageHistoryRun struct {
+	HistoryStage
+	PipelineName    string `json:"pipeline_name"`
+	PipelineCounter int    `json:"pipeline_counter"`
+}
+type StageHistory struct {
+	Stages     []StageHistoryRun `json:"stages"`
+	Pagination Pagination        `json:"pagination"`
+}
+
+// Time is the moment the first job of the stage was scheduled, or the zero time when no job was scheduled.
+func (stage HistoryStage) Time() time.Time {
+	var earliest int64
+	for _, job := range stage.Jobs {
+		if job.ScheduledDate > 0 && (earliest == 0 || job.ScheduledDate < earliest) {
+			earliest = job.ScheduledDate
+		}
+	}
+	if earliest == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, earliest*int64(time.Millisecond)).UTC()
+}
+
+// PipelineHistory fetches a page of the runs of a pipeline, newest first. Each run shows the latest run of its stages.
+func (c Client) PipelineHistory(server string, pipeline string, offset int) (PipelineHistory, error) {
+	if offset < 0 {
+		return PipelineHistory{}, fmt.Errorf("error fetching pipeline history: negative offset %d", offset)
+	}
+
+	path := fmt.Sprintf("/go/api/pipelines/%s/history/%d", url.PathEscape(pipeline), offset)
+	request, err := newGocdRequest("GET", strings.TrimRight(server, "/")+path, c.accept(pipelineHistoryAPI), nil)
+	if err != nil {
+		return PipelineHistory{}, err
+	}
+
+	var history PipelineHistory
+	err = c.performJSON(request, &history)
+	if err != nil {
+		return PipelineHistory{}, err
+	}
+
+	return history, nil
+}
+
+// StageHistory fetches a page of the runs of a stage, newest first. Unlike PipelineHistory, it lists every
+// run of the stage, including those rerun later in the same pipeline run.
+func (c Client) StageHistory(server string, pipeline string, stage string, offset int) (StageHistory, error) {
+	if offset < 0 {
+		return StageHistory{}, fmt.Errorf("error fetching stage history: negative offset %d", offset)
+	}
+
+	path := fmt.Sprintf("/go/api/stages/%s/%s/history/%d", url.PathEscape(pipeline), url.PathEscape(stage), offset)
+	request, err := newGocdRequest("GET", strings.TrimRight(server, "/")+path, c.accept(stageHistoryAPI), nil)
+	if err != nil {
+		return StageHistory{}, err
+	}
+
+	var history StageHistory
+	err = c.performJSON(request, &history)
+	if err != nil {
+		return StageHistory{}, err
+	}
+
+	return history, nil
+}
@@ -0,0 +1,146 @@
+// history_test.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/chiku/gocd"
+)
+
+func TestClientPipelineHistory(t *testing.T) {
+	const serverResponse = `{
+		"pipelines": [{
+			"name": "Build",
+			"counter": 12,
+			"label": "12",
+			"build_cause": { "trigger_message": "modified by dev", "material_revisions": [] },
+			"stages": [{
+				"name": "Test",
+				"counter": "2",
+				"result": "Failed",
+				"approved_by": "changes",
+				"scheduled": true,
+				"rerun_of_counter": 1,
+				"jobs": [{ "name": "Unit", "state": "Completed", "result": "Failed", "scheduled_date": 1500000000000 }]
+			}]
+		}],
+		"pagination": { "offset": 10, "total": 12, "page_size": 10 }
+	}`
+	var path, accept string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, accept = r.URL.EscapedPath(), r.Header.Get("Accept")
+		w.Write([]byte(serverResponse))
+	}))
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	history, err := client.PipelineHistory(ts.URL, "Deploy Prod", 10)
+
+	if err != nil {
+		t.Fatalf("Expected no error fetching pipeline history: %s", err)
+	}
+	if path != "/go/api/pipelines/Deploy%20Prod/history/10" || accept != "application/vnd.go.cd.v1+json" {
+		t.Errorf("Expected proper pipeline history request, but was: %s (%s)", path, accept)
+	}
+	if len(history.Pipelines) != 1 || history.Pagination.HasNext() {
+		t.Fatalf("Expected a last page with one pipeline run, but was: %#v", history)
+	}
+
+	run := history.Pipelines[0]
+	if run.Name != "Build" || run.Counter != 12 || run.Label != "12" || run.BuildCause.TriggerMessage != "modified by dev" {
+		t.Errorf("Expected proper pipeline run, but was: %#v", run)
+	}
+	stage := run.Stages[0]
+	if stage.Name != "Test" || stage.Counter != 2 || stage.Result != "Failed" || stage.RerunOfCounter == nil || *stage.RerunOfCounter != 1 || len(stage.Jobs) != 1 {
+		t.Errorf("Expected proper stage run, but was: %#v", stage)
+	}
+	if !stage.Time().Equal(time.Unix(1500000000, 0)) {
+		t.Errorf("Expected stage time from its jobs, but was: %s", stage.Time())
+	}
+}
+
+func TestClientPipelineHistoryWithNegativeOffset(t *testing.T) {
+	client := gocd.NewClient()
+	_, err := client.PipelineHistory("http://localhost", "Build", -1)
+
+	if err == nil || err.Error() != "error fetching pipeline history: negative offset -1" {
+		t.Errorf("Expected proper error message but was: %v", err)
+	}
+}
+
+func TestClientStageHistory(t *testing.T) {
+	const serverResponse = `{
+		"pagination": { "offset": 0, "total": 2, "page_size": 10 },
+		"stages": [{
+			"name": "Test",
+			"counter": "2",
+			"result": "Passed",
+			"approved_by": "admin",
+			"scheduled": true,
+			"rerun_of_counter": null,
+			"pipeline_name": "Build",
+			"pipeline_counter": 12,
+			"jobs": [{ "name": "Unit", "state": "Completed", "result": "Passed", "scheduled_date": 1500000600000 }]
+		}, {
+			"name": "Test",
+			"counter": "1",
+			"result": "Failed",
+			"approved_by": "changes",
+			"scheduled": true,
+			"rerun_of_counter": null,
+			"pipeline_name": "Build",
+			"pipeline_counter": 12,
+			"jobs": [{ "name": "Unit", "state": "Completed", "result": "Failed", "scheduled_date": 1500000000000 }]
+		}]
+	}`
+	var path, accept string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, accept = r.URL.EscapedPath(), r.Header.Get("Accept")
+		w.Write([]byte(serverResponse))
+	}))
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	history, err := client.StageHistory(ts.URL, "Deploy Prod", "Smoke Test", 10)
+
+	if err != nil {
+		t.Fatalf("Expected no error fetching stage history: %s", err)
+	}
+	if path != "/go/api/stages/Deploy%20Prod/Smoke%20Test/history/10" || accept != "application/vnd.go.cd.v1+json" {
+		t.Errorf("Expected proper stage history request, but was: %s (%s)", path, accept)
+	}
+	if len(history.Stages) != 2 || history.Pagination.HasNext() {
+		t.Fatalf("Expected a last page with two stage runs, but was: %#v", history)
+	}
+
+	rerun, first := history.Stages[0], history.Stages[1]
+	if rerun.PipelineName != "Build" || rerun.PipelineCounter != 12 || rerun.Name != "Test" || rerun.Counter != 2 || rerun.Result != "Passed" {
+		t.Errorf("Expected proper rerun of the stage, but was: %#v", rerun)
+	}
+	if first.PipelineCounter != 12 || first.Counter != 1 || first.Result != "Failed" || !first.Time().Equal(time.Unix(1500000000, 0)) {
+		t.Errorf("Expected proper first run of the stage, but was: %#v", first)
+	}
+}
+
+func TestClientStageHistoryWithNegativeOffset(t *testing.T) {
+	client := gocd.NewClient()
+	_, err := client.StageHistory("http://localhost", "Build", "Test", -1)
+
+	if err == nil || err.Error() != "error fetching stage history: negative offset -1" {
+		t.Errorf("Expected proper error message but was: %v", err)
+	}
+}
+
+func TestHistoryStageTimeWithoutJobs(t *testing.T) {
+	if stage := (gocd.HistoryStage{}); !stage.Time().IsZero() {
+		t.Errorf("Expected zero time for unscheduled stage, but was: %s", stage.Time())
+	}
+}
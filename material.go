@@ -0,0 +1,109 @@
+// material.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var materialTypes = map[string]string{
+	"git":        "git",
+	"subversion": "svn",
+	"svn":        "svn",
+	"mercurial":  "hg",
+	"hg":         "hg",
+	"perforce":   "p4",
+	"p4":         "p4",
+	"tfs":        "tfs",
+	"pipeline":   "dependency",
+	"dependency": "dependency",
+	"package":    "package",
+	"pluggable":  "plugin",
+	"plugin":     "plugin",
+}
+
+type Modification struct {
+	ID           int64  `json:"id"`
+	Revision     string `json:"revision"`
+	UserName     string `json:"user_name"`
+	EmailAddress string `json:"email_address"`
+	Comment      string `json:"comment"`
+	ModifiedTime int64  `json:"modified_time"`
+}
+type Material struct {
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	Fingerprint string `json:"fingerprint"`
+}
+type MaterialRevision struct {
+	Changed       bool           `json:"changed"`
+	Material      Material       `json:"material"`
+	Modifications []Modification `json:"modifications"`
+}
+type BuildCause struct {
+	TriggerMessage    string             `json:"trigger_message"`
+	TriggerForced     bool               `json:"trigger_forced"`
+	Approver          string             `json:"approver"`
+	MaterialRevisions []MaterialRevision `json:"material_revisions"`
+}
+type Pagination struct {
+	Offset   int `json:"offset"`
+	Total    int `json:"total"`
+	PageSize int `json:"page_size"`
+}
+type ModificationHistory struct {
+	Modifications []Modification `json:"modifications"`
+	Pagination    Pagination     `json:"pagination"`
+}
+
+// Time is the moment the modification was made, converted from the milliseconds Gocd reports.
+func (modification Modification) Time() time.Time {
+	return time.Unix(0, modification.ModifiedTime*int64(time.Millisecond)).UTC()
+}
+
+// Kind is the material type in the short form used by pipeline configs: git, svn, hg, p4, tfs or dependency.
+func (material Material) Kind() string {
+	if kind, ok := materialTypes[strings.ToLower(material.Type)]; ok {
+		return kind
+	}
+
+	return strings.ToLower(material.Type)
+}
+
+// HasNext tells if Gocd has older modifications than the ones in this page.
+func (pagination Pagination) HasNext() bool {
+	return pagination.PageSize > 0 && pagination.Offset+pagination.PageSize < pagination.Total
+}
+
+// NextOffset is the offset of the page following this one.
+func (pagination Pagination) NextOffset() int {
+	return pagination.Offset + pagination.PageSize
+}
+
+// MaterialModifications fetches a page of the modification history of a material, newest first.
+func (c Client) MaterialModifications(server string, fingerprint string, offset int) (ModificationHistory, error) {
+	if offset < 0 {
+		return ModificationHistory{}, fmt.Errorf("error fetching modifications: negative offset %d", offset)
+	}
+
+	path := fmt.Sprintf("/go/api/materials/%s/modifications/%d", url.PathEscape(fingerprint), offset)
+	request, err := newGocdRequest("GET", strings.TrimRight(server, "/")+path, c.accept(materialsAPI), nil)
+	if err != nil {
+		return ModificationHistory{}, err
+	}
+
+	var history ModificationHistory
+	err = c.performJSON(request, &history)
+	if err != nil {
+		return ModificationHistory{}, err
+	}
+
+	return history, nil
+}
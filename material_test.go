@@ -0,0 +1,100 @@
+// material_test.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/chiku/gocd"
+)
+
+func TestClientMaterialModifications(t *testing.T) {
+	const serverResponse = `{
+		"modifications": [{
+			"id": 7,
+			"revision": "9f8e7d",
+			"user_name": "dev <dev@example.com>",
+			"email_address": "dev@example.com",
+			"comment": "Fix the build",
+			"modified_time": 1500000000000
+		}],
+		"pagination": { "offset": 10, "total": 25, "page_size": 10 }
+	}`
+	var path string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Write([]byte(serverResponse))
+	}))
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	history, err := client.MaterialModifications(ts.URL, "abc123", 10)
+
+	if err != nil {
+		t.Fatalf("Expected no error fetching modifications: %s", err)
+	}
+	if path != "/go/api/materials/abc123/modifications/10" {
+		t.Errorf("Expected proper modifications path, but was: %s", path)
+	}
+
+	expected := gocd.ModificationHistory{
+		Modifications: []gocd.Modification{{
+			ID:           7,
+			Revision:     "9f8e7d",
+			UserName:     "dev <dev@example.com>",
+			EmailAddress: "dev@example.com",
+			Comment:      "Fix the build",
+			ModifiedTime: 1500000000000,
+		}},
+		Pagination: gocd.Pagination{Offset: 10, Total: 25, PageSize: 10},
+	}
+	if !reflect.DeepEqual(history, expected) {
+		t.Errorf("Expected proper modification history (%#v != %#v)", history, expected)
+	}
+	if !history.Pagination.HasNext() || history.Pagination.NextOffset() != 20 {
+		t.Errorf("Expected another page at offset 20, but was: %#v", history.Pagination)
+	}
+}
+
+func TestClientMaterialModificationsWithNegativeOffset(t *testing.T) {
+	client := gocd.NewClient()
+	_, err := client.MaterialModifications("http://localhost", "abc123", -1)
+
+	if err == nil || err.Error() != "error fetching modifications: negative offset -1" {
+		t.Errorf("Expected proper error message but was: %v", err)
+	}
+}
+
+func TestPaginationOnLastPage(t *testing.T) {
+	pagination := gocd.Pagination{Offset: 20, Total: 25, PageSize: 10}
+
+	if pagination.HasNext() {
+		t.Errorf("Expected no page after the last page: %#v", pagination)
+	}
+}
+
+func TestMaterialKind(t *testing.T) {
+	kinds := map[string]string{
+		"Git":        "git",
+		"Subversion": "svn",
+		"Mercurial":  "hg",
+		"Perforce":   "p4",
+		"Tfs":        "tfs",
+		"Pipeline":   "dependency",
+		"Custom":     "custom",
+	}
+
+	for materialType, expected := range kinds {
+		kind := gocd.Material{Type: materialType}.Kind()
+		if kind != expected {
+			t.Errorf("Expected %s to be of kind %s, but was: %s", materialType, expected, kind)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// metrics.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var stageStatuses = []string{building, "Cancelled", failed, passed, recovering, unknown}
+
+var metricLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// MetricsExporter serves the dashboard in the Prometheus text exposition format. Every scrape fetches
+// the dashboard from URL, keeps and orders the pipelines in Order, as FilteredSort does, and renames
+// them with Names, as MapNames does. An empty Order keeps every pipeline. It is safe for concurrent use.
+type MetricsExporter struct {
+	Client DashboardFetcher
+	URL    string
+	Order  []string
+	Names  map[string]string
+
+	mutex   sync.Mutex
+	fetches int64
+	errors  int64
+}
+
+// NewMetricsExporter creates an exporter for the dashboard at url.
+func NewMetricsExporter(client DashboardFetcher, url string, order []string, names map[string]string) *MetricsExporter {
+	return &MetricsExporter{Client: client, URL: url, Order: order, Names: names}
+}
+
+func (exporter *MetricsExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	started := time.Now()
+	dashboard, err := exporter.Client.Fetch(exporter.URL)
+	elapsed := time.Since(started)
+
+	exporter.mutex.Lock()
+	exporter.fetches++
+	if err != nil {
+		exporter.errors++
+	}
+	fetches, errors := exporter.fetches, exporter.errors
+	exporter.mutex.Unlock()
+
+	var out bytes.Buffer
+	if err == nil {
+		if len(exporter.Order) > 0 {
+			dashboard, _ = dashboard.FilteredSort(exporter.Order)
+		}
+		dashboard = dashboard.MapNames(exporter.Names)
+		writeDashboardMetrics(&out, dashboard)
+	}
+
+	up := 1
+	if err != nil {
+		up = 0
+	}
+	writeMetricHeader(&out, "gocd_up", "gauge", "Whether the last fetch of the Gocd dashboard succeeded.")
+	fmt.Fprintf(&out, "gocd_up %d\n", up)
+	writeMetricHeader(&out, "gocd_fetch_duration_seconds", "gauge", "Time taken by the last fetch of the Gocd dashboard.")
+	fmt.Fprintf(&out, "gocd_fetch_duration_seconds %g\n", elapsed.Seconds())
+	writeMetricHeader(&out, "gocd_fetches_total", "counter", "Fetches of the Gocd dashboard.")
+	fmt.Fprintf(&out, "gocd_fetches_total %d\n", fetches)
+	writeMetricHeader(&out, "gocd_fetch_errors_total", "counter", "Failed fetches of the Gocd dashboard.")
+	fmt.Fprintf(&out, "gocd_fetch_errors_total %d\n", errors)
+
+	w.Header().Set("Content-Type", metricsContentType)
+	w.Write(out.Bytes())
+}
+
+// writeDashboardMetrics exposes each stage status as an enum: the current status is 1 and the others are 0.
+func writeDashboardMetrics(out *bytes.Buffer, dashboard Dashboard) {
+	dashboard = uniquePipelines(dashboard)
+
+	writeMetricHeader(out, "gocd_stage_status", "gauge", "Status of the latest run of a Gocd stage.")
+	for _, pipeline := range dashboard {
+		for _, stage := range pipeline.Stages {
+			known := false
+			for _, status := range stageStatuses {
+				value := 0
+				if strings.EqualFold(stage.Status, status) {
+					value, known = 1, true
+				}
+				fmt.Fprintf(out, "gocd_stage_status{pipeline=\"%s\",stage=\"%s\",status=\"%s\"} %d\n",
+					escapeMetricLabel(pipeline.Name), escapeMetricLabel(stage.Name), status, value)
+			}
+			if !known {
+				fmt.Fprintf(out, "gocd_stage_status{pipeline=\"%s\",stage=\"%s\",status=\"%s\"} 1\n",
+					escapeMetricLabel(pipeline.Name), escapeMetricLabel(stage.Name), escapeMetricLabel(stage.Status))
+			}
+		}
+	}
+
+	writeMetricHeader(out, "gocd_pipeline_failed", "gauge", "Whether a stage of the latest run of a Gocd pipeline failed.")
+	for _, pipeline := range dashboard {
+		value := 0
+		for _, stage := range pipeline.Stages {
+			if strings.EqualFold(stage.Status, failed) {
+				value = 1
+			}
+		}
+		fmt.Fprintf(out, "gocd_pipeline_failed{pipeline=\"%s\"} %d\n", escapeMetricLabel(pipeline.Name), value)
+	}
+}
+
+// uniquePipelines keeps the first of the pipelines and stages sharing a name, as Prometheus rejects
+// scrapes with repeated series. Pipelines share a name when Names maps many to one name.
+func uniquePipelines(dashboard Dashboard) Dashboard {
+	unique := Dashboard{}
+	pipelines := map[string]bool{}
+	for _, pipeline := range dashboard {
+		if pipelines[pipeline.Name] {
+			continue
+		}
+		pipelines[pipeline.Name] = true
+
+		stages := map[string]bool{}
+		uniqueStages := []DashboardStage{}
+		for _, stage := range pipeline.Stages {
+			if !stages[stage.Name] {
+				stages[stage.Name] = true
+				uniqueStages = append(uniqueStages, stage)
+			}
+		}
+		pipeline.Stages = uniqueStages
+		unique = append(unique, pipeline)
+	}
+
+	return unique
+}
+
+func writeMetricHeader(out *bytes.Buffer, name string, kind string, help string) {
+	fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func escapeMetricLabel(value string) string {
+	return metricLabelEscaper.Replace(value)
+}
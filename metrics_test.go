@@ -0,0 +1,130 @@
+// metrics_test.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chiku/gocd"
+)
+
+const metricsDashboardResponse = `[{
+	"name": "Group",
+	"pipelines": [
+		{ "name": "Build", "instances": [{ "stages": [{ "name": "Compile", "status": "Passed" }, { "name": "Test", "status": "Failed" }] }] },
+		{ "name": "Deploy", "instances": [{ "stages": [{ "name": "Deploy", "status": "Building" }] }] },
+		{ "name": "Ignored", "instances": [{ "stages": [{ "name": "Lint", "status": "Passed" }] }] }
+	]
+}]`
+
+func scrape(t *testing.T, exporter http.Handler) string {
+	recorder := httptest.NewRecorder()
+	exporter.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("Expected Prometheus text format, but was: %s", contentType)
+	}
+	return recorder.Body.String()
+}
+
+func TestMetricsExporter(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(metricsDashboardResponse))
+	}))
+	defer ts.Close()
+
+	exporter := gocd.NewMetricsExporter(gocd.NewClient(), ts.URL, []string{"Deploy", "Build"}, map[string]string{"Build": `Build "main"`})
+	metrics := scrape(t, exporter)
+
+	for _, expected := range []string{
+		"# TYPE gocd_stage_status gauge\n",
+		`gocd_stage_status{pipeline="Deploy",stage="Deploy",status="Building"} 1` + "\n",
+		`gocd_stage_status{pipeline="Deploy",stage="Deploy",status="Passed"} 0` + "\n",
+		`gocd_stage_status{pipeline="Build \"main\"",stage="Test",status="Failed"} 1` + "\n",
+		`gocd_pipeline_failed{pipeline="Deploy"} 0` + "\n",
+		`gocd_pipeline_failed{pipeline="Build \"main\""} 1` + "\n",
+		"gocd_up 1\n",
+		"gocd_fetch_duration_seconds ",
+		"gocd_fetches_total 1\n",
+		"gocd_fetch_errors_total 0\n",
+	} {
+		if !strings.Contains(metrics, expected) {
+			t.Errorf("Expected metrics to contain %q, but was:\n%s", expected, metrics)
+		}
+	}
+	if strings.Contains(metrics, "Ignored") || strings.Index(metrics, `pipeline="Deploy"`) > strings.Index(metrics, `pipeline="Build \"main\""`) {
+		t.Errorf("Expected metrics filtered and ordered like the dashboard, but was:\n%s", metrics)
+	}
+}
+
+func TestMetricsExporterWithoutOrder(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(metricsDashboardResponse))
+	}))
+	defer ts.Close()
+
+	metrics := scrape(t, gocd.NewMetricsExporter(gocd.NewClient(), ts.URL, nil, nil))
+
+	if !strings.Contains(metrics, `gocd_pipeline_failed{pipeline="Ignored"} 0`) {
+		t.Errorf("Expected every pipeline without an order, but was:\n%s", metrics)
+	}
+}
+
+func TestMetricsExporterCountsErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	exporter := gocd.NewMetricsExporter(gocd.NewClient(), ts.URL, nil, nil)
+	scrape(t, exporter)
+	metrics := scrape(t, exporter)
+
+	for _, expected := range []string{"gocd_up 0\n", "gocd_fetches_total 2\n", "gocd_fetch_errors_total 2\n"} {
+		if !strings.Contains(metrics, expected) {
+			t.Errorf("Expected metrics to contain %q, but was:\n%s", expected, metrics)
+		}
+	}
+	if strings.Contains(metrics, "gocd_stage_status") {
+		t.Errorf("Expected no stage metrics without a dashboard, but was:\n%s", metrics)
+	}
+}
+
+type dashboardFetcherFunc func(url string) (gocd.Dashboard, error)
+
+func (f dashboardFetcherFunc) Fetch(url string) (gocd.Dashboard, error) {
+	return f(url)
+}
+
+func TestMetricsExporterWithoutDuplicateSeries(t *testing.T) {
+	fetcher := dashboardFetcherFunc(func(url string) (gocd.Dashboard, error) {
+		return gocd.Dashboard{
+			{Name: "Build", Stages: []gocd.DashboardStage{{Name: "Test", Status: "Failed"}, {Name: "Test", Status: "Passed"}}},
+			{Name: "Release", Stages: []gocd.DashboardStage{{Name: "Test", Status: "Passed"}}},
+		}, nil
+	})
+	exporter := gocd.NewMetricsExporter(fetcher, "http://gocd.example.com", []string{"Build", "build", "Release"}, map[string]string{"Release": "Build"})
+
+	metrics := scrape(t, exporter)
+
+	seen := map[string]bool{}
+	for _, line := range strings.Split(strings.TrimSpace(metrics), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		series := line[:strings.LastIndex(line, " ")]
+		if seen[series] {
+			t.Errorf("Expected every series once, but %s repeats in:\n%s", series, metrics)
+		}
+		seen[series] = true
+	}
+	if !strings.Contains(metrics, `gocd_stage_status{pipeline="Build",stage="Test",status="Failed"} 1`+"\n") {
+		t.Errorf("Expected the first of the pipelines sharing a name, but was:\n%s", metrics)
+	}
+}
@@ -0,0 +1,277 @@
+// notification.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// SignatureHeader carries the hex encoded HMAC-SHA256 of a notification body, optionally prefixed by "sha256=".
+	SignatureHeader = "X-GoCD-Signature"
+
+	maxNotificationSize = 1 << 20
+)
+
+// StageNotification is a stage status change, as posted by Gocd notification plugins.
+type StageNotification struct {
+	Pipeline        string
+	PipelineCounter int
+	Stage           string
+	StageCounter    int
+	State           string
+	Result          string
+}
+
+type notificationStage struct {
+	Name    string `json:"name"`
+	Counter string `json:"counter"`
+	State   string `json:"state"`
+	Result  string `json:"result"`
+}
+type notificationPipeline struct {
+	Name    string            `json:"name"`
+	Counter string            `json:"counter"`
+	Stage   notificationStage `json:"stage"`
+}
+type notificationPayload struct {
+	Type     string                `json:"type"`
+	Data     *notificationPayload  `json:"data"`
+	Pipeline *notificationPipeline `json:"pipeline"`
+}
+
+// ParseStageNotification reads a stage status notification, either as sent to plugins by Gocd
+// or wrapped in the type and data envelope of the webhook notification plugin.
+func ParseStageNotification(body []byte) (StageNotification, error) {
+	var payload notificationPayload
+	err := json.Unmarshal(body, &payload)
+	if err != nil {
+		return StageNotification{}, fmt.Errorf("error unmarshalling Gocd notification: %s", err)
+	}
+
+	if payload.Data != nil {
+		if payload.Type != "" && payload.Type != "stage" {
+			return StageNotification{}, fmt.Errorf("error reading Gocd notification: unsupported type %s", payload.Type)
+		}
+		payload = *payload.Data
+	}
+	if payload.Pipeline == nil || payload.Pipeline.Name == "" || payload.Pipeline.Stage.Name == "" {
+		return StageNotification{}, fmt.Errorf("error reading Gocd notification: no pipeline stage")
+	}
+
+	pipeline := payload.Pipeline
+	notification := StageNotification{
+		Pipeline: pipeline.Name,
+		Stage:    pipeline.Stage.Name,
+		State:    pipeline.Stage.State,
+		Result:   pipeline.Stage.Result,
+	}
+	notification.PipelineCounter, _ = strconv.Atoi(pipeline.Counter)
+	notification.StageCounter, _ = strconv.Atoi(pipeline.Stage.Counter)
+
+	return notification, nil
+}
+
+// Status is the stage status in the terms of the dashboard.
+func (notification StageNotification) Status() string {
+	if strings.EqualFold(notification.State, building) || notification.Result == "" || strings.EqualFold(notification.Result, unknown) {
+		return notification.State
+	}
+
+	return notification.Result
+}
+
+type livePipeline struct {
+	DashboardPipeline
+	counter        int
+	stageCounters  map[string]int
+	previousFailed bool
+}
+
+// LiveDashboard is a dashboard kept up to date by stage notifications. It is safe for concurrent use.
+type LiveDashboard struct {
+	mutex       sync.RWMutex
+	pipelines   []*livePipeline
+	lastEventID int64
+	history     []DashboardEvent
+	subscribers map[chan DashboardEvent]bool
+}
+
+// NewLiveDashboard starts a live dashboard from a dashboard fetched from Gocd.
+func NewLiveDashboard(initial Dashboard) *LiveDashboard {
+	live := &LiveDashboard{}
+	for _, pipeline := range initial {
+		live.pipelines = append(live.pipelines, &livePipeline{DashboardPipeline: pipeline})
+	}
+
+	return live
+}
+
+// Dashboard returns a snapshot of the live dashboard.
+func (live *LiveDashboard) Dashboard() Dashboard {
+	live.mutex.RLock()
+	defer live.mutex.RUnlock()
+
+	return live.snapshot()
+}
+
+// Update applies a stage notification. A stage building after its pipeline failed is marked as
+// recovering, just as ToDashboard does. Notifications for an older pipeline run than the one shown,
+// or for an older run of the stage within the pipeline run shown, arrived late and are ignored. It returns the stage as now shown, and whether it changed.
+func (live *LiveDashboard) Update(notification StageNotification) (DashboardStage, bool) {
+	live.mutex.Lock()
+	defer live.mutex.Unlock()
+
+	pipeline := live.findPipeline(notification.Pipeline)
+	if pipeline == nil {
+		pipeline = &livePipeline{DashboardPipeline: DashboardPipeline{Name: notification.Pipeline, Stages: []DashboardStage{}}}
+		live.pipelines = append(live.pipelines, pipeline)
+	}
+
+	if notification.PipelineCounter < pipeline.counter {
+		return pipeline.stage(notification.Stage), false
+	}
+	if notification.PipelineCounter > pipeline.counter {
+		if pipeline.counter > 0 || len(pipeline.Stages) > 0 {
+			pipeline.previousFailed = pipeline.hasFailedStage()
+		}
+		pipeline.counter = notification.PipelineCounter
+		pipeline.stageCounters = nil
+	}
+	if notification.StageCounter < pipeline.stageCounters[notification.Stage] {
+		return pipeline.stage(notification.Stage), false
+	}
+	if pipeline.stageCounters == nil {
+		pipeline.stageCounters = map[string]int{}
+	}
+	pipeline.stageCounters[notification.Stage] = notification.StageCounter
+
+	status := notification.Status()
+	if pipeline.previousFailed && strings.EqualFold(status, building) {
+		status = recovering
+	}
+	stage := DashboardStage{Name: notification.Stage, Status: status}
+
+	for i, existing := range pipeline.Stages {
+		if existing.Name == stage.Name {
+			if existing.Status == stage.Status {
+				return stage, false
+			}
+			pipeline.Stages[i] = stage
+			live.publish(DashboardEvent{Pipeline: pipeline.Name, Stage: stage, PreviousStatus: existing.Status})
+			return stage, true
+		}
+	}
+
+	pipeline.Stages = append(pipeline.Stages, stage)
+	live.publish(DashboardEvent{Pipeline: pipeline.Name, Stage: stage})
+	return stage, true
+}
+
+func (live *LiveDashboard) findPipeline(name string) *livePipeline {
+	for _, pipeline := range live.pipelines {
+		if strings.EqualFold(pipeline.Name, name) {
+			return pipeline
+		}
+	}
+
+	return nil
+}
+
+func (pipeline *livePipeline) stage(name string) DashboardStage {
+	for _, existing := range pipeline.Stages {
+		if existing.Name == name {
+			return existing
+		}
+	}
+
+	return DashboardStage{}
+}
+
+func (pipeline *livePipeline) hasFailedStage() bool {
+	for _, stage := range pipeline.Stages {
+		if strings.EqualFold(stage.Status, failed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NotificationHandler receives stage notifications over HTTP and applies them to a live dashboard.
+// Requests must be signed with the shared secret in SignatureHeader. Without a secret every request
+// is rejected, unless Unsigned explicitly allows unsigned notifications.
+type NotificationHandler struct {
+	Secret    []byte
+	Unsigned  bool
+	Dashboard *LiveDashboard
+}
+
+func (handler NotificationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxNotificationSize+1))
+	if err != nil {
+		http.Error(w, "error reading notification", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxNotificationSize {
+		http.Error(w, "notification too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if len(handler.Secret) == 0 && !handler.Unsigned {
+		http.Error(w, "no shared secret configured", http.StatusUnauthorized)
+		return
+	}
+	if len(handler.Secret) > 0 && !validSignature(handler.Secret, body, r.Header.Get(SignatureHeader)) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	notification, err := ParseStageNotification(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	handler.Dashboard.Update(notification)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"status":"success"}`))
+}
+
+// Sign returns the signature of a notification body, in the form expected in SignatureHeader.
+func Sign(secret []byte, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func validSignature(secret []byte, body []byte, signature string) bool {
+	given, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(signature), "sha256="))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hmac.Equal(given, mac.Sum(nil))
+}
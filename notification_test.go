@@ -0,0 +1,204 @@
+// notification_test.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chiku/gocd"
+)
+
+func webhookNotification(pipeline string, counter int, stage string, state string, result string) string {
+	return fmt.Sprintf(`{
+		"type": "stage",
+		"data": {
+			"pipeline": {
+				"name": %q,
+				"counter": "%d",
+				"group": "Group",
+				"stage": { "name": %q, "counter": "1", "approval-type": "success", "state": %q, "result": %q, "jobs": [] }
+			}
+		}
+	}`, pipeline, counter, stage, state, result)
+}
+
+func postNotification(t *testing.T, handler http.Handler, body string, signature string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest("POST", "/notifications", strings.NewReader(body))
+	if signature != "" {
+		request.Header.Set(gocd.SignatureHeader, signature)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestParseStageNotification(t *testing.T) {
+	plain := `{ "pipeline": { "name": "Build", "counter": "7", "stage": { "name": "Test", "counter": "2", "state": "Passed", "result": "Passed" } } }`
+
+	for _, body := range []string{plain, webhookNotification("Build", 7, "Test", "Passed", "Passed")} {
+		notification, err := gocd.ParseStageNotification([]byte(body))
+		if err != nil {
+			t.Fatalf("Expected no error parsing notification: %s", err)
+		}
+		if notification.Pipeline != "Build" || notification.PipelineCounter != 7 || notification.Stage != "Test" || notification.Status() != "Passed" {
+			t.Errorf("Expected proper notification, but was: %#v", notification)
+		}
+	}
+}
+
+func TestParseStageNotificationOnError(t *testing.T) {
+	_, err := gocd.ParseStageNotification([]byte(`{ "type": "agent", "data": {} }`))
+	if err == nil || err.Error() != "error reading Gocd notification: unsupported type agent" {
+		t.Errorf("Expected error about unsupported type, but was: %v", err)
+	}
+
+	_, err = gocd.ParseStageNotification([]byte(`{}`))
+	if err == nil || err.Error() != "error reading Gocd notification: no pipeline stage" {
+		t.Errorf("Expected error about missing stage, but was: %v", err)
+	}
+}
+
+func TestNotificationHandlerUpdatesDashboard(t *testing.T) {
+	initial := gocd.Dashboard{
+		{Name: "Build", Stages: []gocd.DashboardStage{{Name: "Compile", Status: "Passed"}, {Name: "Test", Status: "Failed"}}},
+		{Name: "Deploy", Stages: []gocd.DashboardStage{{Name: "Deploy", Status: "Passed"}}},
+	}
+	live := gocd.NewLiveDashboard(initial)
+	secret := []byte("shared-secret")
+	handler := gocd.NotificationHandler{Secret: secret, Dashboard: live}
+
+	body := webhookNotification("Build", 8, "Compile", "Building", "Unknown")
+	recorder := postNotification(t, handler, body, gocd.Sign(secret, []byte(body)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected notification to be accepted, but was: %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	body = webhookNotification("Lint", 1, "Check", "Passed", "Passed")
+	postNotification(t, handler, body, gocd.Sign(secret, []byte(body)))
+
+	expected := gocd.Dashboard{
+		{Name: "Build", Stages: []gocd.DashboardStage{{Name: "Compile", Status: "Recovering"}, {Name: "Test", Status: "Failed"}}},
+		{Name: "Deploy", Stages: []gocd.DashboardStage{{Name: "Deploy", Status: "Passed"}}},
+		{Name: "Lint", Stages: []gocd.DashboardStage{{Name: "Check", Status: "Passed"}}},
+	}
+	if dashboard := live.Dashboard(); !reflect.DeepEqual(dashboard, expected) {
+		t.Errorf("Expected dashboard to follow notifications (%#v != %#v)", dashboard, expected)
+	}
+}
+
+func TestNotificationHandlerRejectsInvalidSignature(t *testing.T) {
+	live := gocd.NewLiveDashboard(nil)
+	handler := gocd.NotificationHandler{Secret: []byte("shared-secret"), Dashboard: live}
+	body := webhookNotification("Build", 1, "Compile", "Passed", "Passed")
+
+	for _, signature := range []string{"", "sha256=00", gocd.Sign([]byte("other-secret"), []byte(body))} {
+		recorder := postNotification(t, handler, body, signature)
+		if recorder.Code != http.StatusUnauthorized {
+			t.Errorf("Expected unauthorized for signature %q, but was: %d", signature, recorder.Code)
+		}
+	}
+
+	if dashboard := live.Dashboard(); len(dashboard) != 0 {
+		t.Errorf("Expected dashboard to be untouched, but was: %#v", dashboard)
+	}
+}
+
+func TestNotificationHandlerRejectsRequestsWithoutSecret(t *testing.T) {
+	live := gocd.NewLiveDashboard(nil)
+	handler := gocd.NotificationHandler{Dashboard: live}
+	body := webhookNotification("Build", 1, "Compile", "Passed", "Passed")
+
+	recorder := postNotification(t, handler, body, gocd.Sign(nil, []byte(body)))
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected unauthorized without a shared secret, but was: %d", recorder.Code)
+	}
+	if dashboard := live.Dashboard(); len(dashboard) != 0 {
+		t.Errorf("Expected dashboard to be untouched, but was: %#v", dashboard)
+	}
+
+	handler.Unsigned = true
+	recorder = postNotification(t, handler, body, "")
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected unsigned notification to be accepted when allowed, but was: %d", recorder.Code)
+	}
+}
+
+func TestNotificationHandlerRejectsBadRequests(t *testing.T) {
+	handler := gocd.NotificationHandler{Unsigned: true, Dashboard: gocd.NewLiveDashboard(nil)}
+
+	recorder := postNotification(t, handler, "Random", "")
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected bad request for malformed notification, but was: %d", recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/notifications", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected method not allowed for GET, but was: %d", recorder.Code)
+	}
+}
+
+func TestLiveDashboardIgnoresLateNotifications(t *testing.T) {
+	live := gocd.NewLiveDashboard(nil)
+
+	live.Update(gocd.StageNotification{Pipeline: "Build", PipelineCounter: 12, Stage: "Test", State: "Building"})
+	stage, changed := live.Update(gocd.StageNotification{Pipeline: "Build", PipelineCounter: 11, Stage: "Test", State: "Completed", Result: "Failed"})
+
+	if changed || stage.Status != "Building" {
+		t.Errorf("Expected late notification to be ignored, but was: %#v", stage)
+	}
+	expected := gocd.Dashboard{{Name: "Build", Stages: []gocd.DashboardStage{{Name: "Test", Status: "Building"}}}}
+	if dashboard := live.Dashboard(); !reflect.DeepEqual(dashboard, expected) {
+		t.Errorf("Expected dashboard to show the latest run (%#v != %#v)", dashboard, expected)
+	}
+
+	live.Update(gocd.StageNotification{Pipeline: "Build", PipelineCounter: 13, Stage: "Test", StageCounter: 1, State: "Building"})
+	live.Update(gocd.StageNotification{Pipeline: "Build", PipelineCounter: 13, Stage: "Test", StageCounter: 2, State: "Building"})
+	stage, changed = live.Update(gocd.StageNotification{Pipeline: "Build", PipelineCounter: 13, Stage: "Test", StageCounter: 1, State: "Completed", Result: "Failed"})
+
+	if changed || stage.Status != "Building" {
+		t.Errorf("Expected late notification of an older stage run to be ignored, but was: %#v", stage)
+	}
+	stage, changed = live.Update(gocd.StageNotification{Pipeline: "Build", PipelineCounter: 13, Stage: "Test", StageCounter: 2, State: "Completed", Result: "Passed"})
+	if !changed || stage.Status != "Passed" {
+		t.Errorf("Expected the stage rerun to complete, but was: %#v", stage)
+	}
+	stage, changed = live.Update(gocd.StageNotification{Pipeline: "Build", PipelineCounter: 14, Stage: "Test", StageCounter: 1, State: "Building"})
+	if !changed || stage.Status != "Building" {
+		t.Errorf("Expected stage counters to start again in a new pipeline run, but was: %#v", stage)
+	}
+}
+
+func TestLiveDashboardUpdate(t *testing.T) {
+	live := gocd.NewLiveDashboard(nil)
+
+	stage, changed := live.Update(gocd.StageNotification{Pipeline: "Build", PipelineCounter: 1, Stage: "Test", State: "Failed", Result: "Failed"})
+	if !changed || stage.Status != "Failed" {
+		t.Errorf("Expected new stage to change the dashboard, but was: %#v", stage)
+	}
+
+	_, changed = live.Update(gocd.StageNotification{Pipeline: "Build", PipelineCounter: 1, Stage: "Test", State: "Failed", Result: "Failed"})
+	if changed {
+		t.Errorf("Expected repeated notification to leave the dashboard unchanged")
+	}
+
+	stage, _ = live.Update(gocd.StageNotification{Pipeline: "Build", PipelineCounter: 2, Stage: "Test", State: "Building", Result: "Unknown"})
+	if stage.Status != "Recovering" {
+		t.Errorf("Expected building stage after failure to be recovering, but was: %#v", stage)
+	}
+
+	live.Update(gocd.StageNotification{Pipeline: "Build", PipelineCounter: 2, Stage: "Test", State: "Passed", Result: "Passed"})
+	stage, _ = live.Update(gocd.StageNotification{Pipeline: "Build", PipelineCounter: 3, Stage: "Test", State: "Building", Result: "Unknown"})
+	if stage.Status != "Building" {
+		t.Errorf("Expected building stage after success to be building, but was: %#v", stage)
+	}
+}
@@ -0,0 +1,167 @@
+// observer.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Observer is told about the requests a client makes to Gocd. OnRequestStart is called once per request,
+// OnRetry before every further attempt, and OnResponse once all attempts are done. OnDecode follows
+// when the response body is decoded. OnRequestStart returns the context to send the request with, such as
+// one carrying a span, which the request then carries to the other calls. Observers must be safe for
+// concurrent use.
+type Observer interface {
+	OnRequestStart(request *http.Request) context.Context
+	OnRetry(request *http.Request, attempt int, err error)
+	OnResponse(request *http.Request, response *http.Response, elapsed time.Duration, err error)
+	OnDecode(request *http.Request, format string, elapsed time.Duration, err error)
+}
+
+// WithObserver makes the client report its requests to observer.
+func WithObserver(observer Observer) Option {
+	return func(c *Client) {
+		c.observer = observer
+	}
+}
+
+type nopObserver struct{}
+
+func (nopObserver) OnRequestStart(request *http.Request) context.Context           { return request.Context() }
+func (nopObserver) OnRetry(*http.Request, int, error)                              {}
+func (nopObserver) OnResponse(*http.Request, *http.Response, time.Duration, error) {}
+func (nopObserver) OnDecode(*http.Request, string, time.Duration, error)           {}
+
+func (c Client) observe() Observer {
+	if c.observer == nil {
+		return nopObserver{}
+	}
+
+	return c.observer
+}
+
+type slogObserver struct {
+	logger *slog.Logger
+}
+
+// NewSlogObserver logs requests to Gocd: starts and decoding at debug level, retries as warnings,
+// and responses at info level, or as errors when they fail.
+func NewSlogObserver(logger *slog.Logger) Observer {
+	return slogObserver{logger: logger}
+}
+
+func (observer slogObserver) OnRequestStart(request *http.Request) context.Context {
+	observer.logger.LogAttrs(request.Context(), slog.LevelDebug, "gocd request started",
+		slog.String("method", request.Method), slog.String("url", request.URL.Redacted()))
+
+	return request.Context()
+}
+
+func (observer slogObserver) OnRetry(request *http.Request, attempt int, err error) {
+	observer.logger.LogAttrs(request.Context(), slog.LevelWarn, "gocd request retried",
+		slog.String("method", request.Method), slog.String("url", request.URL.Redacted()),
+		slog.Int("attempt", attempt), slog.Any("error", err))
+}
+
+func (observer slogObserver) OnResponse(request *http.Request, response *http.Response, elapsed time.Duration, err error) {
+	attrs := []slog.Attr{slog.String("method", request.Method), slog.String("url", request.URL.Redacted()), slog.Duration("elapsed", elapsed)}
+	if err != nil {
+		observer.logger.LogAttrs(request.Context(), slog.LevelError, "gocd request failed", append(attrs, slog.Any("error", err))...)
+		return
+	}
+
+	observer.logger.LogAttrs(request.Context(), slog.LevelInfo, "gocd request completed", append(attrs, slog.Int("status", response.StatusCode))...)
+}
+
+func (observer slogObserver) OnDecode(request *http.Request, format string, elapsed time.Duration, err error) {
+	attrs := []slog.Attr{slog.String("url", request.URL.Redacted()), slog.String("format", format), slog.Duration("elapsed", elapsed)}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+
+	observer.logger.LogAttrs(request.Context(), slog.LevelDebug, "gocd response decoded", attrs...)
+}
+
+// Span is the part of a tracing span used by the tracing observer, as found in OpenTelemetry.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	AddEvent(name string, attributes map[string]interface{})
+	RecordError(err error)
+	End(at time.Time)
+}
+
+// Tracer starts spans, returning them with a context carrying them, as OpenTelemetry does.
+// Adapt an OpenTelemetry tracer by passing start with trace.WithTimestamp.
+type Tracer interface {
+	Start(ctx context.Context, name string, start time.Time) (context.Context, Span)
+}
+
+type tracingObserver struct {
+	tracer Tracer
+	mutex  sync.Mutex
+	spans  map[*http.Request]Span
+}
+
+// NewTracingObserver traces every request to Gocd as a "gocd.request" span, with retries as events,
+// and decoding of its response as a "gocd.decode" span. Request spans are children of the span in the request
+// context, and are sent along with the request; decode spans are children of their request span.
+func NewTracingObserver(tracer Tracer) Observer {
+	return &tracingObserver{tracer: tracer, spans: map[*http.Request]Span{}}
+}
+
+func (observer *tracingObserver) OnRequestStart(request *http.Request) context.Context {
+	ctx, span := observer.tracer.Start(request.Context(), "gocd.request", time.Now())
+	span.SetAttribute("http.method", request.Method)
+	span.SetAttribute("http.url", request.URL.Redacted())
+
+	observer.mutex.Lock()
+	observer.spans[request] = span
+	observer.mutex.Unlock()
+
+	return ctx
+}
+
+func (observer *tracingObserver) OnRetry(request *http.Request, attempt int, err error) {
+	observer.mutex.Lock()
+	span := observer.spans[request]
+	observer.mutex.Unlock()
+
+	if span != nil {
+		span.AddEvent("retry", map[string]interface{}{"attempt": attempt, "error": err.Error()})
+	}
+}
+
+func (observer *tracingObserver) OnResponse(request *http.Request, response *http.Response, elapsed time.Duration, err error) {
+	observer.mutex.Lock()
+	span := observer.spans[request]
+	delete(observer.spans, request)
+	observer.mutex.Unlock()
+
+	if span == nil {
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+	} else {
+		span.SetAttribute("http.status_code", response.StatusCode)
+	}
+	span.End(time.Now())
+}
+
+func (observer *tracingObserver) OnDecode(request *http.Request, format string, elapsed time.Duration, err error) {
+	end := time.Now()
+	_, span := observer.tracer.Start(request.Context(), "gocd.decode", end.Add(-elapsed))
+	span.SetAttribute("gocd.format", format)
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End(end)
+}
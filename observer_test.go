@@ -0,0 +1,232 @@
+// observer_test.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chiku/gocd"
+)
+
+type recordingObserver struct {
+	mutex  sync.Mutex
+	events []string
+}
+
+func (observer *recordingObserver) record(event string) {
+	observer.mutex.Lock()
+	defer observer.mutex.Unlock()
+	observer.events = append(observer.events, event)
+}
+
+func (observer *recordingObserver) OnRequestStart(request *http.Request) context.Context {
+	observer.record("start " + request.Method)
+	return request.Context()
+}
+
+func (observer *recordingObserver) OnRetry(request *http.Request, attempt int, err error) {
+	observer.record(fmt.Sprintf("retry %d", attempt))
+}
+
+func (observer *recordingObserver) OnResponse(request *http.Request, response *http.Response, elapsed time.Duration, err error) {
+	if err != nil {
+		observer.record("error")
+		return
+	}
+	observer.record(fmt.Sprintf("response %d", response.StatusCode))
+}
+
+func (observer *recordingObserver) OnDecode(request *http.Request, format string, elapsed time.Duration, err error) {
+	observer.record(fmt.Sprintf("decode %s %t", format, err == nil))
+}
+
+func TestClientReportsToObserver(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/go/api/dashboard" {
+			w.Header().Set("Content-Type", "application/vnd.go.cd.v3+json")
+			w.Write([]byte(`{ "_embedded": { "pipeline_groups": [], "pipelines": [] } }`))
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer ts.Close()
+
+	observer := &recordingObserver{}
+	client := gocd.NewClient(gocd.WithObserver(observer))
+	client.Fetch(ts.URL)
+	client.Fetch(ts.URL + "/go/api/dashboard")
+	client.Agents(ts.URL)
+
+	expected := []string{
+		"start GET", "response 200", "decode legacy true",
+		"start GET", "response 200", "decode hal true",
+		"start GET", "response 200", "decode json false",
+	}
+	if !reflect.DeepEqual(observer.events, expected) {
+		t.Errorf("Expected observer to follow requests (%v != %v)", observer.events, expected)
+	}
+}
+
+func TestClientReportsRetriesToObserver(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.Close()
+
+	observer := &recordingObserver{}
+	gocd.NewClient(gocd.WithObserver(observer)).Fetch(ts.URL)
+
+	expected := []string{"start GET", "retry 2", "retry 3", "error"}
+	if !reflect.DeepEqual(observer.events, expected) {
+		t.Errorf("Expected observer to see retries (%v != %v)", observer.events, expected)
+	}
+}
+
+func TestSlogObserver(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	}))
+	defer ts.Close()
+
+	var out bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	gocd.NewClient(gocd.WithObserver(gocd.NewSlogObserver(logger))).Fetch(ts.URL)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 log lines, but was:\n%s", out.String())
+	}
+	for i, expected := range []string{
+		`level=DEBUG msg="gocd request started" method=GET url=` + ts.URL,
+		`level=INFO msg="gocd request completed" method=GET url=` + ts.URL,
+		`level=DEBUG msg="gocd response decoded" url=` + ts.URL + ` format=legacy`,
+	} {
+		if !strings.Contains(lines[i], expected) {
+			t.Errorf("Expected log line to contain %q, but was: %s", expected, lines[i])
+		}
+	}
+	if !strings.Contains(lines[1], "status=200") {
+		t.Errorf("Expected status in log line, but was: %s", lines[1])
+	}
+}
+
+type fakeSpanKey struct{}
+
+type fakeSpan struct {
+	name       string
+	parent     *fakeSpan
+	start      time.Time
+	end        time.Time
+	attributes map[string]interface{}
+	events     []string
+	err        error
+}
+
+func (span *fakeSpan) SetAttribute(key string, value interface{}) {
+	span.attributes[key] = value
+}
+
+func (span *fakeSpan) AddEvent(name string, attributes map[string]interface{}) {
+	span.events = append(span.events, fmt.Sprintf("%s %v", name, attributes["attempt"]))
+}
+
+func (span *fakeSpan) RecordError(err error) {
+	span.err = err
+}
+
+func (span *fakeSpan) End(at time.Time) {
+	span.end = at
+}
+
+type fakeTracer struct {
+	spans []*fakeSpan
+}
+
+func (tracer *fakeTracer) Start(ctx context.Context, name string, start time.Time) (context.Context, gocd.Span) {
+	parent, _ := ctx.Value(fakeSpanKey{}).(*fakeSpan)
+	span := &fakeSpan{name: name, parent: parent, start: start, attributes: map[string]interface{}{}}
+	tracer.spans = append(tracer.spans, span)
+	return context.WithValue(ctx, fakeSpanKey{}, span), span
+}
+
+func TestTracingObserver(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	}))
+	defer ts.Close()
+
+	tracer := &fakeTracer{}
+	gocd.NewClient(gocd.WithObserver(gocd.NewTracingObserver(tracer))).Fetch(ts.URL)
+
+	if len(tracer.spans) != 2 {
+		t.Fatalf("Expected request and decode spans, but was: %#v", tracer.spans)
+	}
+	request, decode := tracer.spans[0], tracer.spans[1]
+	if request.name != "gocd.request" || request.attributes["http.method"] != "GET" || request.attributes["http.status_code"] != 200 || request.end.IsZero() {
+		t.Errorf("Expected ended request span, but was: %#v", request)
+	}
+	if decode.name != "gocd.decode" || decode.attributes["gocd.format"] != "legacy" || decode.end.Before(decode.start) || decode.start.Before(request.end) {
+		t.Errorf("Expected decode span after the request span, but was: %#v", decode)
+	}
+}
+
+func TestTracingObserverNestsSpans(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{ "version": "18.3.0" }`))
+	}))
+	defer ts.Close()
+
+	var sent *fakeSpan
+	capture := func(base http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+			sent, _ = request.Context().Value(fakeSpanKey{}).(*fakeSpan)
+			return base.RoundTrip(request)
+		})
+	}
+	tracer := &fakeTracer{}
+	client := gocd.NewClient(gocd.WithObserver(gocd.NewTracingObserver(tracer)), gocd.WithTransportWrapper(capture))
+
+	_, err := client.DetectServerVersion(ts.URL)
+	if err != nil {
+		t.Fatalf("Expected no error detecting the version: %s", err)
+	}
+
+	if len(tracer.spans) != 2 {
+		t.Fatalf("Expected request and decode spans, but was: %#v", tracer.spans)
+	}
+	requestSpan, decodeSpan := tracer.spans[0], tracer.spans[1]
+	if sent != requestSpan {
+		t.Errorf("Expected request to be sent with the request span, but was: %#v", sent)
+	}
+	if decodeSpan.parent != requestSpan {
+		t.Errorf("Expected decode span to be a child of the request span, but was: %#v", decodeSpan.parent)
+	}
+}
+
+func TestTracingObserverOnRetries(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.Close()
+
+	tracer := &fakeTracer{}
+	gocd.NewClient(gocd.WithObserver(gocd.NewTracingObserver(tracer))).Fetch(ts.URL)
+
+	if len(tracer.spans) != 1 {
+		t.Fatalf("Expected only a request span, but was: %#v", tracer.spans)
+	}
+	span := tracer.spans[0]
+	if !reflect.DeepEqual(span.events, []string{"retry 2", "retry 3"}) || span.err == nil || span.end.IsZero() {
+		t.Errorf("Expected failed request span with retries, but was: %#v", span)
+	}
+}
@@ -15,6 +15,7 @@ import (
 const (
 	building   = "Building"
 	unknown    = "Unknown"
+	passed     = "Passed"
 	failed     = "Failed"
 	recovering = "Recovering"
 )
@@ -24,7 +25,8 @@ type Stage struct {
 	Status string `json:"status"`
 }
 type Instance struct {
-	Stages []Stage `json:"stages"`
+	Stages     []Stage    `json:"stages"`
+	BuildCause BuildCause `json:"build_cause"`
 }
 type PreviousInstance struct {
 	Result string `json:"result"`
@@ -38,6 +40,30 @@ type PipelineGroup struct {
 	Pipelines []Pipeline `json:"pipelines"`
 }
 type PipelineGroups []PipelineGroup
+type halStage struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+type halInstance struct {
+	Embedded struct {
+		Stages []halStage `json:"stages"`
+	} `json:"_embedded"`
+}
+type halPipeline struct {
+	Name     string `json:"name"`
+	Embedded struct {
+		Instances []halInstance `json:"instances"`
+	} `json:"_embedded"`
+}
+type halDashboard struct {
+	Embedded struct {
+		PipelineGroups []struct {
+			Name      string   `json:"name"`
+			Pipelines []string `json:"pipelines"`
+		} `json:"pipeline_groups"`
+		Pipelines []halPipeline `json:"pipelines"`
+	} `json:"_embedded"`
+}
 
 func NewPipelineGroups(body []byte) (PipelineGroups, error) {
 	var dashboard []PipelineGroup
@@ -48,23 +74,50 @@ func NewPipelineGroups(body []byte) (PipelineGroups, error) {
 	return dashboard, nil
 }
 
+// NewPipelineGroupsFromHAL reads the dashboard served by the dashboard API of newer Gocd servers.
+// That format carries no previous instance, so stages of the latest instance are not marked as recovering.
+func NewPipelineGroupsFromHAL(body []byte) (PipelineGroups, error) {
+	var dashboard halDashboard
+	err := json.Unmarshal(body, &dashboard)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling Gocd JSON: %s", err.Error())
+	}
+
+	pipelines := map[string]Pipeline{}
+	for _, halPipeline := range dashboard.Embedded.Pipelines {
+		pipeline := Pipeline{Name: halPipeline.Name}
+		for _, halInstance := range halPipeline.Embedded.Instances {
+			instance := Instance{}
+			for _, stage := range halInstance.Embedded.Stages {
+				instance.Stages = append(instance.Stages, Stage{Name: stage.Name, Status: stage.Status})
+			}
+			// The dashboard API lists the newest instance first, unlike the legacy dashboard
+			pipeline.Instances = append([]Instance{instance}, pipeline.Instances...)
+		}
+		pipelines[halPipeline.Name] = pipeline
+	}
+
+	groups := PipelineGroups{}
+	for _, halGroup := range dashboard.Embedded.PipelineGroups {
+		group := PipelineGroup{}
+		for _, name := range halGroup.Pipelines {
+			if pipeline, ok := pipelines[name]; ok {
+				group.Pipelines = append(group.Pipelines, pipeline)
+			}
+		}
+		groups = append(groups, group)
+	}
+
+	return groups, nil
+}
+
 func (groups *PipelineGroups) ToDashboard() Dashboard {
 	dashboard := Dashboard{}
 
 	for _, group := range *groups {
 		for _, pipeline := range group.Pipelines {
-			displayName := pipeline.Name
-			stages := []DashboardStage{}
-			if len(pipeline.Instances) > 0 {
-
-				instance := pipeline.Instances[len(pipeline.Instances)-1]
-				for _, stage := range instance.Stages {
-					status := traverseStatusInInstances(stage, pipeline.Instances, pipeline.PreviousInstance)
-					stages = append(stages, DashboardStage{Name: stage.Name, Status: status})
-				}
-				if len(stages) > 0 {
-					dashboard = append(dashboard, DashboardPipeline{Name: displayName, Stages: stages})
-				}
+			if dashboardPipeline, ok := pipeline.toDashboard(); ok {
+				dashboard = append(dashboard, dashboardPipeline)
 			}
 		}
 	}
@@ -72,6 +125,47 @@ func (groups *PipelineGroups) ToDashboard() Dashboard {
 	return dashboard
 }
 
+// toDashboard shows the latest instance of the pipeline, unless it has no stages.
+func (pipeline Pipeline) toDashboard() (DashboardPipeline, bool) {
+	if len(pipeline.Instances) == 0 {
+		return DashboardPipeline{}, false
+	}
+
+	stages := []DashboardStage{}
+	instance := pipeline.Instances[len(pipeline.Instances)-1]
+	for _, stage := range instance.Stages {
+		status := traverseStatusInInstances(stage, pipeline.Instances, pipeline.PreviousInstance)
+		stages = append(stages, DashboardStage{Name: stage.Name, Status: status})
+	}
+	if len(stages) == 0 {
+		return DashboardPipeline{}, false
+	}
+
+	materials := dashboardMaterials(instance.BuildCause)
+	return DashboardPipeline{Name: pipeline.Name, Stages: stages, Materials: materials}, true
+}
+
+func dashboardMaterials(cause BuildCause) (materials []DashboardMaterial) {
+	for _, revision := range cause.MaterialRevisions {
+		material := DashboardMaterial{
+			Type:        revision.Material.Kind(),
+			Description: revision.Material.Description,
+			Changed:     revision.Changed,
+		}
+		if len(revision.Modifications) > 0 {
+			latest := revision.Modifications[0]
+			modifiedTime := latest.Time()
+			material.Revision = latest.Revision
+			material.Author = latest.UserName
+			material.Message = latest.Comment
+			material.ModifiedTime = &modifiedTime
+		}
+		materials = append(materials, material)
+	}
+
+	return
+}
+
 func traverseStatusInInstances(currentStage Stage, instances []Instance, previousInstance PreviousInstance) string {
 	selfStatus := currentStage.Status
 	previousInstanceResult := previousInstance.Result
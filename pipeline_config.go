@@ -0,0 +1,458 @@
+// pipeline_config.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+type EnvironmentVariable struct {
+	Name           string                     `json:"name"`
+	Value          string                     `json:"value,omitempty"`
+	EncryptedValue string                     `json:"encrypted_value,omitempty"`
+	Secure         bool                       `json:"secure"`
+	Extra          map[string]json.RawMessage `json:"-"`
+}
+type MaterialFilter struct {
+	Ignore []string                   `json:"ignore"`
+	Extra  map[string]json.RawMessage `json:"-"`
+}
+type MaterialAttributes struct {
+	Name                string                     `json:"name,omitempty"`
+	URL                 string                     `json:"url,omitempty"`
+	Branch              string                     `json:"branch,omitempty"`
+	Destination         string                     `json:"destination,omitempty"`
+	Username            string                     `json:"username,omitempty"`
+	Password            string                     `json:"password,omitempty"`
+	EncryptedPassword   string                     `json:"encrypted_password,omitempty"`
+	CheckExternals      bool                       `json:"check_externals,omitempty"`
+	ShallowClone        bool                       `json:"shallow_clone,omitempty"`
+	Port                string                     `json:"port,omitempty"`
+	UseTickets          bool                       `json:"use_tickets,omitempty"`
+	View                string                     `json:"view,omitempty"`
+	Domain              string                     `json:"domain,omitempty"`
+	ProjectPath         string                     `json:"project_path,omitempty"`
+	Pipeline            string                     `json:"pipeline,omitempty"`
+	Stage               string                     `json:"stage,omitempty"`
+	IgnoreForScheduling bool                       `json:"ignore_for_scheduling,omitempty"`
+	AutoUpdate          bool                       `json:"auto_update"`
+	InvertFilter        bool                       `json:"invert_filter,omitempty"`
+	Filter              *MaterialFilter            `json:"filter,omitempty"`
+	Extra               map[string]json.RawMessage `json:"-"`
+}
+type MaterialConfig struct {
+	Type       string                     `json:"type"`
+	Attributes MaterialAttributes         `json:"attributes"`
+	Extra      map[string]json.RawMessage `json:"-"`
+}
+type TaskAttributes struct {
+	RunIf            []string                   `json:"run_if,omitempty"`
+	Command          string                     `json:"command,omitempty"`
+	Arguments        []string                   `json:"arguments,omitempty"`
+	WorkingDirectory string                     `json:"working_directory,omitempty"`
+	BuildFile        string                     `json:"build_file,omitempty"`
+	Target           string                     `json:"target,omitempty"`
+	Pipeline         string                     `json:"pipeline,omitempty"`
+	Stage            string                     `json:"stage,omitempty"`
+	Job              string                     `json:"job,omitempty"`
+	Source           string                     `json:"source,omitempty"`
+	IsSourceAFile    bool                       `json:"is_source_a_file,omitempty"`
+	Destination      string                     `json:"destination,omitempty"`
+	OnCancel         *Task                      `json:"on_cancel,omitempty"`
+	Extra            map[string]json.RawMessage `json:"-"`
+}
+type Task struct {
+	Type       string                     `json:"type"`
+	Attributes TaskAttributes             `json:"attributes"`
+	Extra      map[string]json.RawMessage `json:"-"`
+}
+type ArtifactConfig struct {
+	Type        string                     `json:"type"`
+	Source      string                     `json:"source,omitempty"`
+	Destination string                     `json:"destination,omitempty"`
+	Extra       map[string]json.RawMessage `json:"-"`
+}
+type JobConfig struct {
+	Name                 string                     `json:"name"`
+	RunInstanceCount     interface{}                `json:"run_instance_count,omitempty"`
+	Timeout              interface{}                `json:"timeout,omitempty"`
+	ElasticProfileID     string                     `json:"elastic_profile_id,omitempty"`
+	EnvironmentVariables []EnvironmentVariable      `json:"environment_variables"`
+	Resources            []string                   `json:"resources"`
+	Tasks                []Task                     `json:"tasks"`
+	Artifacts            []ArtifactConfig           `json:"artifacts"`
+	Extra                map[string]json.RawMessage `json:"-"`
+}
+type Authorization struct {
+	Roles []string                   `json:"roles"`
+	Users []string                   `json:"users"`
+	Extra map[string]json.RawMessage `json:"-"`
+}
+type Approval struct {
+	Type               string                     `json:"type"`
+	AllowOnlyOnSuccess bool                       `json:"allow_only_on_success"`
+	Authorization      Authorization              `json:"authorization"`
+	Extra              map[string]json.RawMessage `json:"-"`
+}
+type StageConfig struct {
+	Name                  string                     `json:"name"`
+	FetchMaterials        bool                       `json:"fetch_materials"`
+	CleanWorkingDirectory bool                       `json:"clean_working_directory"`
+	NeverCleanupArtifacts bool                       `json:"never_cleanup_artifacts"`
+	Approval              *Approval                  `json:"approval,omitempty"`
+	EnvironmentVariables  []EnvironmentVariable      `json:"environment_variables"`
+	Jobs                  []JobConfig                `json:"jobs"`
+	Extra                 map[string]json.RawMessage `json:"-"`
+}
+
+// PipelineConfig is the configuration of a pipeline as managed through the pipeline config API.
+// ETag carries the version read from Gocd, and is required to update the pipeline. Extra, in this and
+// every other config type, holds the attributes Gocd sent that are not modelled here, so that updating
+// a config read from Gocd sends them back unchanged instead of deleting them.
+type PipelineConfig struct {
+	Name                 string                     `json:"name"`
+	Group                string                     `json:"group,omitempty"`
+	LabelTemplate        string                     `json:"label_template,omitempty"`
+	LockBehavior         string                     `json:"lock_behavior,omitempty"`
+	Template             string                     `json:"template,omitempty"`
+	EnvironmentVariables []EnvironmentVariable      `json:"environment_variables"`
+	Materials            []MaterialConfig           `json:"materials"`
+	Stages               []StageConfig              `json:"stages"`
+	ETag                 string                     `json:"-"`
+	Extra                map[string]json.RawMessage `json:"-"`
+}
+type pipelineConfigCreate struct {
+	Group    string         `json:"group"`
+	Pipeline PipelineConfig `json:"pipeline"`
+}
+
+// ConflictError is returned when a pipeline config changed on Gocd since it was read.
+type ConflictError struct {
+	Name    string
+	ETag    string
+	Message string
+}
+
+func (err *ConflictError) Error() string {
+	return fmt.Sprintf("error updating pipeline config %s: it was modified since version %s: %s", err.Name, err.ETag, err.Message)
+}
+
+// PipelineConfig fetches the configuration of a pipeline along with its ETag.
+func (c Client) PipelineConfig(server string, name string) (PipelineConfig, error) {
+	request, err := newGocdRequest("GET", pipelineConfigURL(server, name), c.accept(pipelineConfigAPI), nil)
+	if err != nil {
+		return PipelineConfig{}, err
+	}
+
+	return c.performPipelineConfig(request, name, "")
+}
+
+// CreatePipelineConfig creates a pipeline in the group named by its config.
+func (c Client) CreatePipelineConfig(server string, config PipelineConfig) (PipelineConfig, error) {
+	if config.Group == "" {
+		return PipelineConfig{}, fmt.Errorf("error creating pipeline config %s: no group given", config.Name)
+	}
+
+	payload, err := json.Marshal(pipelineConfigCreate{Group: config.Group, Pipeline: config})
+	if err != nil {
+		return PipelineConfig{}, fmt.Errorf("error marshalling pipeline config: %s", err)
+	}
+
+	request, err := newGocdRequest("POST", strings.TrimRight(server, "/")+"/go/api/admin/pipelines", c.accept(pipelineConfigAPI), payload)
+	if err != nil {
+		return PipelineConfig{}, err
+	}
+
+	return c.performPipelineConfig(request, config.Name, "")
+}
+
+// UpdatePipelineConfig replaces a pipeline config, provided it is unchanged on Gocd since it was read.
+// A concurrent edit results in a *ConflictError.
+func (c Client) UpdatePipelineConfig(server string, config PipelineConfig) (PipelineConfig, error) {
+	if config.ETag == "" {
+		return PipelineConfig{}, fmt.Errorf("error updating pipeline config %s: no ETag given", config.Name)
+	}
+
+	payload, err := json.Marshal(config)
+	if err != nil {
+		return PipelineConfig{}, fmt.Errorf("error marshalling pipeline config: %s", err)
+	}
+
+	request, err := newGocdRequest("PUT", pipelineConfigURL(server, config.Name), c.accept(pipelineConfigAPI), payload)
+	if err != nil {
+		return PipelineConfig{}, err
+	}
+	request.Header.Set("If-Match", config.ETag)
+
+	return c.performPipelineConfig(request, config.Name, config.ETag)
+}
+
+// DeletePipelineConfig deletes a pipeline.
+func (c Client) DeletePipelineConfig(server string, name string) error {
+	request, err := newGocdRequest("DELETE", pipelineConfigURL(server, name), c.accept(pipelineConfigAPI), nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.perform(request)
+	return err
+}
+
+func (c Client) performPipelineConfig(request *http.Request, name string, etag string) (PipelineConfig, error) {
+	response, err := c.receive(request)
+	if err != nil {
+		return PipelineConfig{}, err
+	}
+
+	if response.StatusCode == http.StatusPreconditionFailed {
+		defer response.Body.Close()
+		message := readErrorBody(response)
+		var body operationResponse
+		if json.Unmarshal([]byte(message), &body) == nil && body.Message != "" {
+			message = body.Message
+		}
+		return PipelineConfig{}, &ConflictError{Name: name, ETag: etag, Message: message}
+	}
+
+	body, err := readHTTPResponse(response)
+	if err != nil {
+		return PipelineConfig{}, err
+	}
+	err = checkNotHTML(response, "JSON")
+	if err != nil {
+		return PipelineConfig{}, err
+	}
+
+	var config PipelineConfig
+	err = json.Unmarshal(body, &config)
+	if err != nil {
+		return PipelineConfig{}, fmt.Errorf("error unmarshalling Gocd JSON: %s", err)
+	}
+	config.ETag = response.Header.Get("ETag")
+
+	return config, nil
+}
+
+func (variable *EnvironmentVariable) UnmarshalJSON(data []byte) error {
+	type plain EnvironmentVariable
+	extra, err := unmarshalKeepingExtra(data, (*plain)(variable))
+	variable.Extra = extra
+	return err
+}
+
+func (variable EnvironmentVariable) MarshalJSON() ([]byte, error) {
+	type plain EnvironmentVariable
+	return marshalWithExtra(plain(variable), variable.Extra)
+}
+
+func (filter *MaterialFilter) UnmarshalJSON(data []byte) error {
+	type plain MaterialFilter
+	extra, err := unmarshalKeepingExtra(data, (*plain)(filter))
+	filter.Extra = extra
+	return err
+}
+
+func (filter MaterialFilter) MarshalJSON() ([]byte, error) {
+	type plain MaterialFilter
+	return marshalWithExtra(plain(filter), filter.Extra)
+}
+
+func (attributes *MaterialAttributes) UnmarshalJSON(data []byte) error {
+	type plain MaterialAttributes
+	extra, err := unmarshalKeepingExtra(data, (*plain)(attributes))
+	attributes.Extra = extra
+	return err
+}
+
+func (attributes MaterialAttributes) MarshalJSON() ([]byte, error) {
+	type plain MaterialAttributes
+	return marshalWithExtra(plain(attributes), attributes.Extra)
+}
+
+func (material *MaterialConfig) UnmarshalJSON(data []byte) error {
+	type plain MaterialConfig
+	extra, err := unmarshalKeepingExtra(data, (*plain)(material))
+	material.Extra = extra
+	return err
+}
+
+func (material MaterialConfig) MarshalJSON() ([]byte, error) {
+	type plain MaterialConfig
+	return marshalWithExtra(plain(material), material.Extra)
+}
+
+func (attributes *TaskAttributes) UnmarshalJSON(data []byte) error {
+	type plain TaskAttributes
+	extra, err := unmarshalKeepingExtra(data, (*plain)(attributes))
+	attributes.Extra = extra
+	return err
+}
+
+func (attributes TaskAttributes) MarshalJSON() ([]byte, error) {
+	type plain TaskAttributes
+	return marshalWithExtra(plain(attributes), attributes.Extra)
+}
+
+func (task *Task) UnmarshalJSON(data []byte) error {
+	type plain Task
+	extra, err := unmarshalKeepingExtra(data, (*plain)(task))
+	task.Extra = extra
+	return err
+}
+
+func (task Task) MarshalJSON() ([]byte, error) {
+	type plain Task
+	return marshalWithExtra(plain(task), task.Extra)
+}
+
+func (artifact *ArtifactConfig) UnmarshalJSON(data []byte) error {
+	type plain ArtifactConfig
+	extra, err := unmarshalKeepingExtra(data, (*plain)(artifact))
+	artifact.Extra = extra
+	return err
+}
+
+func (artifact ArtifactConfig) MarshalJSON() ([]byte, error) {
+	type plain ArtifactConfig
+	return marshalWithExtra(plain(artifact), artifact.Extra)
+}
+
+func (job *JobConfig) UnmarshalJSON(data []byte) error {
+	type plain JobConfig
+	extra, err := unmarshalKeepingExtra(data, (*plain)(job))
+	job.Extra = extra
+	return err
+}
+
+func (job JobConfig) MarshalJSON() ([]byte, error) {
+	type plain JobConfig
+	return marshalWithExtra(plain(job), job.Extra)
+}
+
+func (authorization *Authorization) UnmarshalJSON(data []byte) error {
+	type plain Authorization
+	extra, err := unmarshalKeepingExtra(data, (*plain)(authorization))
+	authorization.Extra = extra
+	return err
+}
+
+func (authorization Authorization) MarshalJSON() ([]byte, error) {
+	type plain Authorization
+	return marshalWithExtra(plain(authorization), authorization.Extra)
+}
+
+func (approval *Approval) UnmarshalJSON(data []byte) error {
+	type plain Approval
+	extra, err := unmarshalKeepingExtra(data, (*plain)(approval))
+	approval.Extra = extra
+	return err
+}
+
+func (approval Approval) MarshalJSON() ([]byte, error) {
+	type plain Approval
+	return marshalWithExtra(plain(approval), approval.Extra)
+}
+
+func (stage *StageConfig) UnmarshalJSON(data []byte) error {
+	type plain StageConfig
+	extra, err := unmarshalKeepingExtra(data, (*plain)(stage))
+	stage.Extra = extra
+	return err
+}
+
+func (stage StageConfig) MarshalJSON() ([]byte, error) {
+	type plain StageConfig
+	return marshalWithExtra(plain(stage), stage.Extra)
+}
+
+func (config *PipelineConfig) UnmarshalJSON(data []byte) error {
+	type plain PipelineConfig
+	extra, err := unmarshalKeepingExtra(data, (*plain)(config))
+	config.Extra = extra
+	return err
+}
+
+func (config PipelineConfig) MarshalJSON() ([]byte, error) {
+	type plain PipelineConfig
+	return marshalWithExtra(plain(config), config.Extra)
+}
+
+// unmarshalKeepingExtra reads data into target, and returns the members of data that target has no field for.
+func unmarshalKeepingExtra(data []byte, target interface{}) (map[string]json.RawMessage, error) {
+	err := json.Unmarshal(data, target)
+	if err != nil {
+		return nil, err
+	}
+
+	var members map[string]json.RawMessage
+	err = json.Unmarshal(data, &members)
+	if err != nil {
+		return nil, err
+	}
+
+	names := jsonNames(reflect.TypeOf(target).Elem())
+	for key := range members {
+		for _, name := range names {
+			if strings.EqualFold(key, name) {
+				delete(members, key)
+				break
+			}
+		}
+	}
+	if len(members) == 0 {
+		return nil, nil
+	}
+
+	return members, nil
+}
+
+// marshalWithExtra writes source, along with the extra members it has no field for.
+func marshalWithExtra(source interface{}, extra map[string]json.RawMessage) ([]byte, error) {
+	body, err := json.Marshal(source)
+	if err != nil || len(extra) == 0 {
+		return body, err
+	}
+
+	var members map[string]json.RawMessage
+	err = json.Unmarshal(body, &members)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range extra {
+		if _, ok := members[key]; !ok {
+			members[key] = value
+		}
+	}
+
+	return json.Marshal(members)
+}
+
+func jsonNames(kind reflect.Type) []string {
+	names := []string{}
+	for i := 0; i < kind.NumField(); i++ {
+		field := kind.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names = append(names, name)
+	}
+
+	return names
+}
+
+func pipelineConfigURL(server string, name string) string {
+	return strings.TrimRight(server, "/") + "/go/api/admin/pipelines/" + url.PathEscape(name)
+}
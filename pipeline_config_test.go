@@ -0,0 +1,240 @@
+// pipeline_config_test.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/chiku/gocd"
+)
+
+type fakePipelineConfigServer struct {
+	config  map[string]interface{}
+	version int
+}
+
+func (server *fakePipelineConfigServer) etag() string {
+	return fmt.Sprintf(`"v%d"`, server.version)
+}
+
+func (server *fakePipelineConfigServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Accept") != "application/vnd.go.cd.v11+json" {
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+
+	body, _ := ioutil.ReadAll(r.Body)
+	switch {
+	case r.Method == "POST" && r.URL.Path == "/go/api/admin/pipelines":
+		var create struct {
+			Group    string                 `json:"group"`
+			Pipeline map[string]interface{} `json:"pipeline"`
+		}
+		json.Unmarshal(body, &create)
+		server.config = create.Pipeline
+		server.config["group"] = create.Group
+		server.version = 1
+	case r.URL.Path != "/go/api/admin/pipelines/Pipeline":
+		http.NotFound(w, r)
+		return
+	case r.Method == "PUT":
+		if r.Header.Get("If-Match") != server.etag() {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			w.Write([]byte(`{ "message": "Someone has modified the configuration for pipeline 'Pipeline'." }`))
+			return
+		}
+		server.config = nil
+		json.Unmarshal(body, &server.config)
+		server.version++
+	case r.Method == "DELETE":
+		server.config = nil
+		w.Write([]byte(`{ "message": "The pipeline 'Pipeline' was deleted successfully." }`))
+		return
+	}
+
+	w.Header().Set("ETag", server.etag())
+	output, _ := json.Marshal(server.config)
+	w.Write(output)
+}
+
+func samplePipelineConfig() gocd.PipelineConfig {
+	return gocd.PipelineConfig{
+		Name:                 "Pipeline",
+		Group:                "Group",
+		LabelTemplate:        "${COUNT}",
+		EnvironmentVariables: []gocd.EnvironmentVariable{{Name: "TOKEN", EncryptedValue: "AES:abc", Secure: true}},
+		Materials: []gocd.MaterialConfig{
+			{Type: "git", Attributes: gocd.MaterialAttributes{URL: "https://example.com/repo.git", Branch: "main", AutoUpdate: true}},
+			{Type: "dependency", Attributes: gocd.MaterialAttributes{Pipeline: "Upstream", Stage: "Build", AutoUpdate: true}},
+		},
+		Stages: []gocd.StageConfig{{
+			Name:           "Build",
+			FetchMaterials: true,
+			Approval:       &gocd.Approval{Type: "success", Authorization: gocd.Authorization{Roles: []string{}, Users: []string{}}},
+			Jobs: []gocd.JobConfig{{
+				Name:      "compile",
+				Resources: []string{"linux"},
+				Tasks:     []gocd.Task{{Type: "exec", Attributes: gocd.TaskAttributes{RunIf: []string{"passed"}, Command: "make", Arguments: []string{"all"}}}},
+			}},
+		}},
+	}
+}
+
+func TestClientPipelineConfigLifecycle(t *testing.T) {
+	fake := &fakePipelineConfigServer{}
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	created, err := client.CreatePipelineConfig(ts.URL, samplePipelineConfig())
+	if err != nil {
+		t.Fatalf("Expected no error creating pipeline config: %s", err)
+	}
+	if created.ETag != `"v1"` {
+		t.Errorf("Expected ETag of created pipeline, but was: %s", created.ETag)
+	}
+
+	config, err := client.PipelineConfig(ts.URL, "Pipeline")
+	if err != nil {
+		t.Fatalf("Expected no error fetching pipeline config: %s", err)
+	}
+	expected := samplePipelineConfig()
+	expected.ETag = `"v1"`
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("Expected pipeline config to round trip (%#v != %#v)", config, expected)
+	}
+
+	config.LabelTemplate = "1.${COUNT}"
+	updated, err := client.UpdatePipelineConfig(ts.URL, config)
+	if err != nil {
+		t.Fatalf("Expected no error updating pipeline config: %s", err)
+	}
+	if updated.ETag != `"v2"` || updated.LabelTemplate != "1.${COUNT}" {
+		t.Errorf("Expected updated pipeline config, but was: %#v", updated)
+	}
+
+	err = client.DeletePipelineConfig(ts.URL, "Pipeline")
+	if err != nil {
+		t.Fatalf("Expected no error deleting pipeline config: %s", err)
+	}
+	if fake.config != nil {
+		t.Errorf("Expected pipeline config to be deleted, but was: %#v", fake.config)
+	}
+}
+
+func TestClientUpdatePipelineConfigWithStaleETag(t *testing.T) {
+	fake := &fakePipelineConfigServer{config: map[string]interface{}{"name": "Pipeline"}, version: 3}
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	config := samplePipelineConfig()
+	config.ETag = `"v2"`
+	_, err := client.UpdatePipelineConfig(ts.URL, config)
+
+	conflict, ok := err.(*gocd.ConflictError)
+	if !ok {
+		t.Fatalf("Expected conflict error, but was: %#v", err)
+	}
+	if conflict.Name != "Pipeline" || conflict.ETag != `"v2"` || conflict.Message != "Someone has modified the configuration for pipeline 'Pipeline'." {
+		t.Errorf("Expected proper conflict error, but was: %#v", conflict)
+	}
+	if fake.version != 3 {
+		t.Errorf("Expected pipeline config to be untouched, but was at version %d", fake.version)
+	}
+}
+
+func TestClientUpdatePipelineConfigWithoutETag(t *testing.T) {
+	client := gocd.NewClient()
+	_, err := client.UpdatePipelineConfig("http://localhost", samplePipelineConfig())
+
+	if err == nil || err.Error() != "error updating pipeline config Pipeline: no ETag given" {
+		t.Errorf("Expected error without ETag, but was: %v", err)
+	}
+}
+
+func TestClientCreatePipelineConfigWithoutGroup(t *testing.T) {
+	client := gocd.NewClient()
+	_, err := client.CreatePipelineConfig("http://localhost", gocd.PipelineConfig{Name: "Pipeline"})
+
+	if err == nil || err.Error() != "error creating pipeline config Pipeline: no group given" {
+		t.Errorf("Expected error without group, but was: %v", err)
+	}
+}
+
+const pipelineConfigWithUnmodelledFields = `{
+	"name": "Pipeline",
+	"label_template": "${COUNT}",
+	"parameters": [{ "name": "ENV", "value": "staging" }],
+	"timer": { "spec": "0 0 22 ? * MON-FRI", "only_on_changes": true },
+	"tracking_tool": { "type": "generic", "attributes": { "url_pattern": "https://example.com/${ID}", "regex": "#(\\d+)" } },
+	"origin": { "type": "gocd" },
+	"environment_variables": [],
+	"materials": [{ "type": "git", "attributes": { "url": "https://example.com/repo.git", "auto_update": true, "submodule_folder": "vendor" } }],
+	"stages": [{
+		"name": "Build",
+		"fetch_materials": true,
+		"clean_working_directory": false,
+		"never_cleanup_artifacts": false,
+		"approval": { "type": "success", "allow_only_on_success": false, "authorization": { "roles": [], "users": [] } },
+		"environment_variables": [],
+		"jobs": [{
+			"name": "compile",
+			"environment_variables": [],
+			"resources": [],
+			"tabs": [{ "name": "Report", "path": "report.html" }],
+			"tasks": [{ "type": "pluggable_task", "attributes": { "run_if": ["passed"], "plugin_configuration": { "id": "script-executor", "version": "1" }, "configuration": [{ "key": "script", "value": "make" }] } }],
+			"artifacts": []
+		}]
+	}]
+}`
+
+func TestClientUpdatePipelineConfigKeepsUnmodelledFields(t *testing.T) {
+	fake := &fakePipelineConfigServer{version: 1}
+	json.Unmarshal([]byte(pipelineConfigWithUnmodelledFields), &fake.config)
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	config, err := client.PipelineConfig(ts.URL, "Pipeline")
+	if err != nil {
+		t.Fatalf("Expected no error fetching pipeline config: %s", err)
+	}
+
+	config.LabelTemplate = "1.${COUNT}"
+	_, err = client.UpdatePipelineConfig(ts.URL, config)
+	if err != nil {
+		t.Fatalf("Expected no error updating pipeline config: %s", err)
+	}
+
+	var expected map[string]interface{}
+	json.Unmarshal([]byte(pipelineConfigWithUnmodelledFields), &expected)
+	expected["label_template"] = "1.${COUNT}"
+	if !reflect.DeepEqual(fake.config, expected) {
+		actual, _ := json.Marshal(fake.config)
+		t.Errorf("Expected fields not modelled to survive an update, but was: %s", actual)
+	}
+}
+
+func TestStageConfigWithoutApproval(t *testing.T) {
+	body, err := json.Marshal(gocd.StageConfig{Name: "Build"})
+	if err != nil {
+		t.Fatalf("Expected no error marshalling stage config: %s", err)
+	}
+
+	var stage map[string]interface{}
+	json.Unmarshal(body, &stage)
+	if _, ok := stage["approval"]; ok {
+		t.Errorf("Expected no approval in stage config, but was: %s", body)
+	}
+}
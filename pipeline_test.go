@@ -10,8 +10,10 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/chiku/gocd"
+	"github.com/chiku/gocd/gocdtest"
 )
 
 func TestToDashboard(t *testing.T) {
@@ -259,6 +261,38 @@ func TestToDashboardWithMultiplePipelineGroupsPipelinesInstancesAndStages(t *tes
 	}
 }
 
+func TestToDashboardWithMaterials(t *testing.T) {
+	stages := []gocd.Stage{{Name: "Stage One", Status: "Failed"}}
+	cause := gocd.BuildCause{MaterialRevisions: []gocd.MaterialRevision{{
+		Changed:  true,
+		Material: gocd.Material{Type: "Subversion", Description: "URL: https://example.com/svn"},
+		Modifications: []gocd.Modification{
+			{Revision: "42", UserName: "dev", Comment: "Newest", ModifiedTime: 1500000000000},
+			{Revision: "41", UserName: "dev", Comment: "Older", ModifiedTime: 1400000000000},
+		},
+	}, {
+		Material: gocd.Material{Type: "Pipeline", Description: "Upstream"},
+	}}}
+	instances := []gocd.Instance{{Stages: stages, BuildCause: cause}}
+	pipelines := []gocd.Pipeline{{Name: "Pipeline One", Instances: instances}}
+	groups := gocd.PipelineGroups{gocd.PipelineGroup{Pipelines: pipelines}}
+
+	dashboard := groups.ToDashboard()
+
+	if len(dashboard) != 1 {
+		t.Fatalf("Expected 1 item in dashboard, but has %d items: dashboard: %#v", len(dashboard), dashboard)
+	}
+
+	modifiedTime := time.Date(2017, time.July, 14, 2, 40, 0, 0, time.UTC)
+	expected := []gocd.DashboardMaterial{
+		{Type: "svn", Description: "URL: https://example.com/svn", Revision: "42", Author: "dev", Message: "Newest", ModifiedTime: &modifiedTime, Changed: true},
+		{Type: "dependency", Description: "Upstream"},
+	}
+	if !reflect.DeepEqual(dashboard[0].Materials, expected) {
+		t.Errorf("Expected latest material revisions (%#v != %#v)", dashboard[0].Materials, expected)
+	}
+}
+
 func TestToDashboardWithoutPipelineGroups(t *testing.T) {
 	groups := gocd.PipelineGroups{}
 	dashboard := groups.ToDashboard()
@@ -372,6 +406,113 @@ func TestNewPipelineGroups(t *testing.T) {
 	}
 }
 
+func TestNewPipelineGroupsWithBuildCause(t *testing.T) {
+	const dashboardJSON = `[{
+	  "name": "Group",
+	  "pipelines": [{
+	    "name": "Pipeline",
+	    "instances": [{
+	      "stages": [{ "name": "StageOne", "status": "Failed" }],
+	      "build_cause": {
+	        "trigger_message": "modified by dev <dev@example.com>",
+	        "trigger_forced": false,
+	        "approver": "",
+	        "material_revisions": [{
+	          "changed": true,
+	          "material": { "type": "Git", "description": "URL: https://example.com/repo.git, Branch: main", "fingerprint": "abc123" },
+	          "modifications": [{
+	            "revision": "9f8e7d",
+	            "user_name": "dev <dev@example.com>",
+	            "comment": "Break the build",
+	            "modified_time": 1500000000000
+	          }]
+	        }]
+	      }
+	    }]
+	  }]
+	}]`
+
+	groups, err := gocd.NewPipelineGroups([]byte(dashboardJSON))
+
+	if err != nil {
+		t.Fatalf("Expected no error when creating pipeline groups from valid JSON, but was: %s", err)
+	}
+
+	cause := groups[0].Pipelines[0].Instances[0].BuildCause
+	expected := gocd.BuildCause{
+		TriggerMessage: "modified by dev <dev@example.com>",
+		MaterialRevisions: []gocd.MaterialRevision{{
+			Changed:  true,
+			Material: gocd.Material{Type: "Git", Description: "URL: https://example.com/repo.git, Branch: main", Fingerprint: "abc123"},
+			Modifications: []gocd.Modification{{
+				Revision:     "9f8e7d",
+				UserName:     "dev <dev@example.com>",
+				Comment:      "Break the build",
+				ModifiedTime: 1500000000000,
+			}},
+		}},
+	}
+	if !reflect.DeepEqual(cause, expected) {
+		t.Errorf("Expected proper build cause (%#v != %#v)", cause, expected)
+	}
+}
+
+func TestNewPipelineGroupsFromHAL(t *testing.T) {
+	const dashboardJSON = `{
+	  "_embedded": {
+	    "pipeline_groups": [
+	      { "name": "Group One", "pipelines": ["Pipeline", "Missing"] },
+	      { "name": "Group Two", "pipelines": [] }
+	    ],
+	    "pipelines": [{
+	      "name": "Pipeline",
+	      "_embedded": {
+	        "instances": [
+	          { "_embedded": { "stages": [{ "name": "StageOne", "status": "Unknown" }] } },
+	          { "_embedded": { "stages": [{ "name": "StageOne", "status": "Passed" }] } }
+	        ]
+	      }
+	    }]
+	  }
+	}`
+
+	groups, err := gocd.NewPipelineGroupsFromHAL([]byte(dashboardJSON))
+
+	if err != nil {
+		t.Fatalf("Expected no error when creating pipeline groups from valid HAL JSON, but was: %s", err)
+	}
+
+	expected := gocd.PipelineGroups{
+		{Pipelines: []gocd.Pipeline{{
+			Name: "Pipeline",
+			Instances: []gocd.Instance{
+				{Stages: []gocd.Stage{{Name: "StageOne", Status: "Passed"}}},
+				{Stages: []gocd.Stage{{Name: "StageOne", Status: "Unknown"}}},
+			},
+		}}},
+		{},
+	}
+	if !reflect.DeepEqual(groups, expected) {
+		t.Errorf("Expected pipeline groups with oldest instance first (%#v != %#v)", groups, expected)
+	}
+
+	dashboard := groups.ToDashboard()
+	if len(dashboard) != 1 || dashboard[0].Stages[0].Status != "Passed" {
+		t.Errorf("Expected unknown status to fall back to older instance, but was: %#v", dashboard)
+	}
+}
+
+func TestNewPipelineGroupsFromHALOnError(t *testing.T) {
+	groups, err := gocd.NewPipelineGroupsFromHAL([]byte(`Random`))
+
+	if err == nil || !strings.Contains(err.Error(), "error unmarshalling Gocd JSON: ") {
+		t.Errorf("Expected error message about JSON unmarshall error, but was: %v", err)
+	}
+	if groups != nil {
+		t.Fatalf("Expected no invalid groups, but was: %#v", groups)
+	}
+}
+
 func TestNewPipelineGroupsOnError(t *testing.T) {
 	groups, err := gocd.NewPipelineGroups([]byte(`Random`))
 
@@ -387,3 +528,134 @@ func TestNewPipelineGroupsOnError(t *testing.T) {
 		t.Fatalf("Expected no invalid groups, but was: %#v", groups)
 	}
 }
+
+func FuzzNewPipelineGroups(f *testing.F) {
+	f.Add([]byte(`[{ "name": "Group", "pipelines": [{ "name": "Pipeline", "instances": [{ "stages": [{ "name": "Stage", "status": "Unknown" }] }], "previous_instance": { "result": "Failed" } }] }]`))
+	f.Add([]byte(`[{ "pipelines": [{ "instances": [{ "stages": [] }, null] }] }, null]`))
+	f.Add([]byte(`null`))
+	f.Add([]byte(`Random`))
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		groups, err := gocd.NewPipelineGroups(body)
+		if err != nil {
+			if groups != nil {
+				t.Errorf("Expected no invalid groups, but was: %#v", groups)
+			}
+			return
+		}
+
+		for _, pipeline := range groups.ToDashboard() {
+			if len(pipeline.Stages) == 0 {
+				t.Errorf("Expected no pipeline without stages on dashboard, but was: %#v", pipeline)
+			}
+		}
+	})
+}
+
+func TestToDashboardStatusTraversalScenarios(t *testing.T) {
+	scenarios := []struct {
+		name     string
+		fixture  *gocdtest.Fixture
+		expected string
+	}{
+		{
+			name:     "latest known status",
+			fixture:  gocdtest.NewFixture().Group("Group").Pipeline("Pipeline").Instance(gocdtest.Failed("Stage")).Instance(gocdtest.Passed("Stage")),
+			expected: "Passed",
+		},
+		{
+			name:     "older known status when latest is unknown",
+			fixture:  gocdtest.NewFixture().Group("Group").Pipeline("Pipeline").Instance(gocdtest.Failed("Stage")).Instance(gocdtest.Unknown("Stage")),
+			expected: "Failed",
+		},
+		{
+			name: "newest of the older known statuses",
+			fixture: gocdtest.NewFixture().Group("Group").Pipeline("Pipeline").
+				Instance(gocdtest.Passed("Stage")).
+				Instance(gocdtest.Cancelled("Stage")).
+				Instance(gocdtest.Unknown("Stage")).
+				Instance(gocdtest.Unknown("Stage")),
+			expected: "Cancelled",
+		},
+		{
+			name:     "status of the stage with the same name only",
+			fixture:  gocdtest.NewFixture().Group("Group").Pipeline("Pipeline").Instance(gocdtest.Passed("Stage"), gocdtest.Failed("Other")).Instance(gocdtest.Unknown("Stage")),
+			expected: "Passed",
+		},
+		{
+			name:     "previous result when every instance is unknown",
+			fixture:  gocdtest.NewFixture().Group("Group").Pipeline("Pipeline").PreviousResult("Failed").Instance(gocdtest.Unknown("Stage")).Instance(gocdtest.Unknown("Stage")),
+			expected: "Failed",
+		},
+		{
+			name:     "unknown without any known status",
+			fixture:  gocdtest.NewFixture().Group("Group").Pipeline("Pipeline").PreviousResult("Unknown").Instance(gocdtest.Unknown("Stage")),
+			expected: "Unknown",
+		},
+		{
+			name:     "recovering when building after a failure",
+			fixture:  gocdtest.NewFixture().Group("Group").Pipeline("Pipeline").PreviousResult("Failed").Instance(gocdtest.Building("Stage")),
+			expected: "Recovering",
+		},
+		{
+			name:     "building after a pass",
+			fixture:  gocdtest.NewFixture().Group("Group").Pipeline("Pipeline").PreviousResult("Passed").Instance(gocdtest.Building("Stage")),
+			expected: "Building",
+		},
+	}
+
+	for _, scenario := range scenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			for format, groups := range map[string]gocd.PipelineGroups{
+				"structs": scenario.fixture.PipelineGroups(),
+				"json":    mustPipelineGroups(t, scenario.fixture.JSON()),
+			} {
+				dashboard := groups.ToDashboard()
+				if len(dashboard) != 1 || len(dashboard[0].Stages) == 0 {
+					t.Fatalf("Expected the pipeline on the dashboard from %s, but was: %#v", format, dashboard)
+				}
+				if status := dashboard[0].Stages[0].Status; status != scenario.expected {
+					t.Errorf("Expected status from %s to be %s, but was: %s", format, scenario.expected, status)
+				}
+			}
+		})
+	}
+}
+
+func mustPipelineGroups(t *testing.T, body []byte) gocd.PipelineGroups {
+	groups, err := gocd.NewPipelineGroups(body)
+	if err != nil {
+		t.Fatalf("Expected no error reading pipeline groups, but was: %s", err)
+	}
+
+	return groups
+}
+
+func FuzzToDashboardStatusTraversal(f *testing.F) {
+	f.Add("Unknown", "Failed", "Building", "Failed")
+	f.Add("Passed", "Unknown", "Unknown", "")
+	f.Add("unknown", "UNKNOWN", "building", "Unknown")
+
+	f.Fuzz(func(t *testing.T, oldest string, older string, latest string, previous string) {
+		instances := []gocd.Instance{
+			{Stages: []gocd.Stage{{Name: "Stage", Status: oldest}}},
+			{Stages: []gocd.Stage{{Name: "Other", Status: older}, {Name: "Stage", Status: older}}},
+			{Stages: []gocd.Stage{{Name: "Stage", Status: latest}}},
+		}
+		pipelines := []gocd.Pipeline{{Name: "Pipeline", Instances: instances, PreviousInstance: gocd.PreviousInstance{Result: previous}}}
+		groups := gocd.PipelineGroups{gocd.PipelineGroup{Pipelines: pipelines}}
+
+		dashboard := groups.ToDashboard()
+
+		if len(dashboard) != 1 || len(dashboard[0].Stages) != 1 {
+			t.Fatalf("Expected one stage from the latest instance, but was: %#v", dashboard)
+		}
+		status := dashboard[0].Stages[0].Status
+		for _, candidate := range []string{oldest, older, latest, previous, "Recovering", "Unknown"} {
+			if status == candidate {
+				return
+			}
+		}
+		t.Errorf("Expected status from the instances, but was: %q", status)
+	})
+}
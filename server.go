@@ -0,0 +1,213 @@
+// server.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	agentsAPI          = "agents"
+	configReposAPI     = "config_repos"
+	dashboardAPI       = "dashboard"
+	environmentsAPI    = "environments"
+	materialsAPI       = "materials"
+	pipelineConfigAPI  = "pipeline_config"
+	pipelineHistoryAPI = "pipeline_history"
+	serverHealthAPI    = "server_health"
+	stageCancelAPI     = "stage_cancel"
+	stageHistoryAPI    = "stage_history"
+	stageRunAPI        = "stage_run"
+	versionAPI         = "version"
+
+	legacyDashboardPath = "/go/dashboard.json"
+	dashboardPath       = "/go/api/dashboard"
+)
+
+type apiVersion struct {
+	since   string
+	version string
+}
+
+// apiVersions lists, oldest first, the Gocd release from which each API version is served.
+// Servers older than the first release get the first version, and unknown servers get the newest.
+var apiVersions = map[string][]apiVersion{
+	agentsAPI:          {{"16.7.0", "v4"}, {"19.3.0", "v5"}, {"19.8.0", "v6"}, {"20.5.0", "v7"}},
+	configReposAPI:     {{"18.2.0", "v1"}, {"19.8.0", "v2"}, {"20.2.0", "v3"}, {"20.3.0", "v4"}},
+	dashboardAPI:       {{"17.12.0", "v2"}, {"18.3.0", "v3"}},
+	environmentsAPI:    {{"16.7.0", "v2"}, {"19.9.0", "v3"}},
+	materialsAPI:       {{"14.3.0", "v1"}},
+	pipelineConfigAPI:  {{"16.10.0", "v5"}, {"18.7.0", "v6"}, {"19.4.0", "v7"}, {"19.6.0", "v8"}, {"19.10.0", "v9"}, {"20.1.0", "v10"}, {"20.6.0", "v11"}},
+	pipelineHistoryAPI: {{"14.3.0", "v1"}},
+	serverHealthAPI:    {{"18.1.0", "v1"}},
+	stageCancelAPI:     {{"19.12.0", "v3"}},
+	stageHistoryAPI:    {{"14.3.0", "v1"}},
+	stageRunAPI:        {{"19.9.0", "v2"}},
+	versionAPI:         {{"16.6.0", "v1"}},
+}
+
+type ServerVersion struct {
+	Version     string `json:"version"`
+	BuildNumber string `json:"build_number"`
+	GitSHA      string `json:"git_sha"`
+	FullVersion string `json:"full_version"`
+	CommitURL   string `json:"commit_url"`
+}
+type HealthMessage struct {
+	Message string `json:"message"`
+	Detail  string `json:"detail"`
+	Level   string `json:"level"`
+	Time    string `json:"time"`
+}
+
+// AtLeast tells if the server runs the given release or a newer one.
+func (version ServerVersion) AtLeast(release string) bool {
+	return compareReleases(version.Version, release) >= 0
+}
+
+// IsError tells if the message reports a problem rather than a warning.
+func (message HealthMessage) IsError() bool {
+	return strings.EqualFold(message.Level, "ERROR")
+}
+
+// ServerVersion fetches the version of the Gocd server.
+func (c Client) ServerVersion(server string) (ServerVersion, error) {
+	request, err := newGocdRequest("GET", strings.TrimRight(server, "/")+"/go/api/version", c.accept(versionAPI), nil)
+	if err != nil {
+		return ServerVersion{}, err
+	}
+
+	var version ServerVersion
+	err = c.performJSON(request, &version)
+	if err != nil {
+		return ServerVersion{}, err
+	}
+
+	return version, nil
+}
+
+// DetectServerVersion fetches the version of the Gocd server and remembers it,
+// so that later requests use the API versions and payloads this server understands.
+// It is safe to call while other requests are made, which pick the version up once detected.
+// Copies of a client made by NewClient share the version detected.
+func (c *Client) DetectServerVersion(server string) (ServerVersion, error) {
+	version, err := c.ServerVersion(server)
+	if err != nil {
+		return ServerVersion{}, err
+	}
+
+	if c.version == nil {
+		c.version = &atomic.Value{}
+	}
+	c.version.Store(version.Version)
+	return version, nil
+}
+
+func (c Client) serverVersion() string {
+	if c.version == nil {
+		return ""
+	}
+
+	version, _ := c.version.Load().(string)
+	return version
+}
+
+// ServerHealthMessages fetches the warnings and errors Gocd shows on its dashboard.
+func (c Client) ServerHealthMessages(server string) ([]HealthMessage, error) {
+	request, err := newGocdRequest("GET", strings.TrimRight(server, "/")+"/go/api/server_health_messages", c.accept(serverHealthAPI), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []HealthMessage
+	err = c.performJSON(request, &messages)
+	if err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// FetchDashboard fetches the dashboard from the endpoint matching the server version:
+// the legacy dashboard JSON for old servers and the dashboard API for newer ones.
+func (c Client) FetchDashboard(server string) (Dashboard, error) {
+	path := dashboardPath
+	if version := c.serverVersion(); version != "" && compareReleases(version, apiVersions[dashboardAPI][0].since) < 0 {
+		path = legacyDashboardPath
+	}
+
+	request, err := newGocdRequest("GET", strings.TrimRight(server, "/")+path, c.accept(dashboardAPI), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.fetch(request)
+}
+
+func (c Client) accept(api string) string {
+	return acceptVersion(c.apiVersion(api))
+}
+
+func acceptVersion(version string) string {
+	return "application/vnd.go.cd." + version + "+json"
+}
+
+// apiVersion is the version of an API the server understands, which decides the payloads sent and read.
+func (c Client) apiVersion(api string) string {
+	versions := apiVersions[api]
+	chosen := versions[len(versions)-1]
+	if server := c.serverVersion(); server != "" {
+		chosen = versions[0]
+		for _, version := range versions {
+			if compareReleases(server, version.since) >= 0 {
+				chosen = version
+			}
+		}
+	}
+
+	return chosen.version
+}
+
+func compareReleases(left string, right string) int {
+	leftParts := releaseParts(left)
+	rightParts := releaseParts(right)
+
+	for i := 0; i < len(leftParts) || i < len(rightParts); i++ {
+		var l, r int
+		if i < len(leftParts) {
+			l = leftParts[i]
+		}
+		if i < len(rightParts) {
+			r = rightParts[i]
+		}
+		if l != r {
+			if l < r {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return 0
+}
+
+func releaseParts(release string) (parts []int) {
+	release = strings.SplitN(strings.TrimSpace(release), " ", 2)[0]
+	release = strings.SplitN(release, "-", 2)[0]
+
+	for _, part := range strings.Split(release, ".") {
+		number, err := strconv.Atoi(part)
+		if err != nil {
+			break
+		}
+		parts = append(parts, number)
+	}
+
+	return
+}
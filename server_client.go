@@ -0,0 +1,220 @@
+// server_client.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// Server is a client bound to one Gocd server, building the URLs of every endpoint from its base URL.
+type Server struct {
+	client *Client
+	base   string
+}
+
+// NewServer creates a client for the Gocd server at baseURL, such as https://ci.example.com or
+// https://ci.example.com/go/. The /go context path and trailing slashes are optional. Options are
+// those of NewClient, and it fails when they do not go together.
+func NewServer(baseURL string, options ...Option) (*Server, error) {
+	base, err := normalizeBaseURL(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	client := NewClient(options...)
+	if client.err != nil {
+		return nil, client.err
+	}
+
+	return &Server{client: client, base: base}, nil
+}
+
+func normalizeBaseURL(baseURL string) (string, error) {
+	parsed, err := url.Parse(strings.TrimSpace(baseURL))
+	if err != nil {
+		return "", fmt.Errorf("error parsing Gocd base URL: %s", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return "", fmt.Errorf("error parsing Gocd base URL: %q is not an http or https URL", baseURL)
+	}
+	if parsed.Host == "" {
+		return "", fmt.Errorf("error parsing Gocd base URL: %q has no host", baseURL)
+	}
+	if parsed.RawQuery != "" || parsed.Fragment != "" {
+		return "", fmt.Errorf("error parsing Gocd base URL: %q has a query or fragment", baseURL)
+	}
+
+	parsed.Path = strings.TrimRight(parsed.Path, "/")
+	parsed.Path = strings.TrimSuffix(parsed.Path, "/go")
+	parsed.RawPath = ""
+
+	return parsed.String(), nil
+}
+
+// BaseURL is the URL of the server, without the /go context path.
+func (server *Server) BaseURL() string {
+	return server.base
+}
+
+// Client is the client making requests to the server.
+func (server *Server) Client() *Client {
+	return server.client
+}
+
+// URL is the full URL of a path under the /go context path, such as /api/agents.
+func (server *Server) URL(path string) string {
+	return server.base + "/go/" + strings.TrimLeft(path, "/")
+}
+
+// Dashboard fetches the dashboard, from the dashboard API when the server is known to support it.
+func (server *Server) Dashboard() (Dashboard, error) {
+	return server.client.FetchDashboard(server.base)
+}
+
+// DetectVersion fetches the version of the server, and uses the API versions it supports from then on.
+func (server *Server) DetectVersion() (ServerVersion, error) {
+	return server.client.DetectServerVersion(server.base)
+}
+
+func (server *Server) Version() (ServerVersion, error) {
+	return server.client.ServerVersion(server.base)
+}
+
+func (server *Server) HealthMessages() ([]HealthMessage, error) {
+	return server.client.ServerHealthMessages(server.base)
+}
+
+func (server *Server) Agents() ([]Agent, error) {
+	return server.client.Agents(server.base)
+}
+
+func (server *Server) Agent(uuid string) (Agent, error) {
+	return server.client.Agent(server.base, uuid)
+}
+
+func (server *Server) EnableAgents(uuids ...string) error {
+	return server.client.EnableAgents(server.base, uuids...)
+}
+
+func (server *Server) DisableAgents(uuids ...string) error {
+	return server.client.DisableAgents(server.base, uuids...)
+}
+
+func (server *Server) UpdateAgentResources(uuids []string, add []string, remove []string) error {
+	return server.client.UpdateAgentResources(server.base, uuids, add, remove)
+}
+
+func (server *Server) Artifacts(job JobLocator) ([]Artifact, error) {
+	return server.client.Artifacts(server.base, job)
+}
+
+func (server *Server) DownloadArtifact(job JobLocator, path string, writer io.Writer) (int64, error) {
+	return server.client.DownloadArtifact(server.base, job, path, writer)
+}
+
+func (server *Server) DownloadArtifactDirectory(job JobLocator, path string, writer io.Writer) (int64, error) {
+	return server.client.DownloadArtifactDirectory(server.base, job, path, writer)
+}
+
+func (server *Server) DownloadVerifiedArtifact(job JobLocator, path string, writer io.Writer) (int64, error) {
+	return server.client.DownloadVerifiedArtifact(server.base, job, path, writer)
+}
+
+func (server *Server) ArtifactChecksums(job JobLocator) (map[string]string, error) {
+	return server.client.ArtifactChecksums(server.base, job)
+}
+
+func (server *Server) ConsoleLog(job JobLocator, offset int64) (io.ReadCloser, error) {
+	return server.client.ConsoleLog(server.base, job, offset)
+}
+
+func (server *Server) ConfigRepos() ([]ConfigRepo, error) {
+	return server.client.ConfigRepos(server.base)
+}
+
+func (server *Server) ConfigReposWithPipelines() ([]ConfigRepo, error) {
+	return server.client.ConfigReposWithPipelines(server.base)
+}
+
+func (server *Server) ConfigRepoPipelines(id string) ([]string, error) {
+	return server.client.ConfigRepoPipelines(server.base, id)
+}
+
+func (server *Server) TriggerConfigRepoUpdate(id string) (string, error) {
+	return server.client.TriggerConfigRepoUpdate(server.base, id)
+}
+
+func (server *Server) Environments() ([]Environment, error) {
+	return server.client.Environments(server.base)
+}
+
+func (server *Server) FeedPipelines() ([]FeedPipeline, error) {
+	return server.client.FeedPipelines(server.base)
+}
+
+func (server *Server) StageFeed(pipeline string) (StageFeed, error) {
+	return server.client.StageFeed(server.base, pipeline)
+}
+
+func (server *Server) StageFeedSince(pipeline string, lastID string) ([]StageFeedEntry, error) {
+	return server.client.StageFeedSince(server.base, pipeline, lastID)
+}
+
+func (server *Server) PipelineHistory(pipeline string, offset int) (PipelineHistory, error) {
+	return server.client.PipelineHistory(server.base, pipeline, offset)
+}
+
+func (server *Server) StageHistory(pipeline string, stage string, offset int) (StageHistory, error) {
+	return server.client.StageHistory(server.base, pipeline, stage, offset)
+}
+
+func (server *Server) MaterialModifications(fingerprint string, offset int) (ModificationHistory, error) {
+	return server.client.MaterialModifications(server.base, fingerprint, offset)
+}
+
+func (server *Server) PipelineConfig(name string) (PipelineConfig, error) {
+	return server.client.PipelineConfig(server.base, name)
+}
+
+func (server *Server) CreatePipelineConfig(config PipelineConfig) (PipelineConfig, error) {
+	return server.client.CreatePipelineConfig(server.base, config)
+}
+
+func (server *Server) UpdatePipelineConfig(config PipelineConfig) (PipelineConfig, error) {
+	return server.client.UpdatePipelineConfig(server.base, config)
+}
+
+func (server *Server) DeletePipelineConfig(name string) error {
+	return server.client.DeletePipelineConfig(server.base, name)
+}
+
+func (server *Server) CancelStage(stage StageLocator) (string, error) {
+	return server.client.CancelStage(server.base, stage)
+}
+
+func (server *Server) RerunStage(stage StageLocator) (string, error) {
+	return server.client.RerunStage(server.base, stage)
+}
+
+func (server *Server) ApproveStage(pipeline string, pipelineCounter int, stage string) (string, error) {
+	return server.client.ApproveStage(server.base, pipeline, pipelineCounter, stage)
+}
+
+func (server *Server) RerunFailedJobs(stage StageLocator) (string, error) {
+	return server.client.RerunFailedJobs(server.base, stage)
+}
+
+func (server *Server) RerunSelectedJobs(stage StageLocator, jobs []string) (string, error) {
+	return server.client.RerunSelectedJobs(server.base, stage, jobs)
+}
+
+func (server *Server) ValueStreamMap(pipeline string, counter int) (ValueStreamMap, error) {
+	return server.client.ValueStreamMap(server.base, pipeline, counter)
+}
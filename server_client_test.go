@@ -0,0 +1,103 @@
+// server_client_test.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chiku/gocd"
+)
+
+func TestNewServerNormalizesBaseURL(t *testing.T) {
+	for baseURL, expected := range map[string]string{
+		"https://ci.example.com":          "https://ci.example.com",
+		"https://ci.example.com/":         "https://ci.example.com",
+		"https://ci.example.com/go":       "https://ci.example.com",
+		"https://ci.example.com/go/":      "https://ci.example.com",
+		" http://ci.example.com:8153/go ": "http://ci.example.com:8153",
+		"https://example.com/ci/go//":     "https://example.com/ci",
+		"https://example.com/gocd":        "https://example.com/gocd",
+	} {
+		server, err := gocd.NewServer(baseURL)
+		if err != nil {
+			t.Errorf("Expected no error for %q: %s", baseURL, err)
+			continue
+		}
+		if server.BaseURL() != expected {
+			t.Errorf("Expected base URL of %q to be %s, but was: %s", baseURL, expected, server.BaseURL())
+		}
+		if server.URL("/api/agents") != expected+"/go/api/agents" {
+			t.Errorf("Expected endpoint URL under the context path, but was: %s", server.URL("/api/agents"))
+		}
+	}
+}
+
+func TestNewServerOnError(t *testing.T) {
+	for baseURL, expected := range map[string]string{
+		"ci.example.com":              `error parsing Gocd base URL: "ci.example.com" is not an http or https URL`,
+		"ftp://ci.example.com":        `error parsing Gocd base URL: "ftp://ci.example.com" is not an http or https URL`,
+		"https://":                    `error parsing Gocd base URL: "https://" has no host`,
+		"https://ci.example.com/?x=1": `error parsing Gocd base URL: "https://ci.example.com/?x=1" has a query or fragment`,
+		"https://ci.example.com/#top": `error parsing Gocd base URL: "https://ci.example.com/#top" has a query or fragment`,
+		"http://ci example.com":       `error parsing Gocd base URL: `,
+	} {
+		server, err := gocd.NewServer(baseURL)
+		if err == nil || !strings.HasPrefix(err.Error(), expected) {
+			t.Errorf("Expected error for %q to be %s, but was: %v", baseURL, expected, err)
+		}
+		if server != nil {
+			t.Errorf("Expected no server for %q, but was: %#v", baseURL, server)
+		}
+	}
+}
+
+func TestServerEndpoints(t *testing.T) {
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		switch r.URL.Path {
+		case "/go/api/version":
+			w.Write([]byte(`{ "version": "17.3.0" }`))
+		case "/go/dashboard.json":
+			w.Write([]byte(`[{ "name": "Group", "pipelines": [{ "name": "Pipeline", "instances": [{ "stages": [{ "name": "Stage", "status": "Passed" }] }] }] }]`))
+		case "/go/api/agents":
+			w.Write([]byte(`{ "_embedded": { "agents": [{ "uuid": "abc", "hostname": "agent-1" }] } }`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	server, err := gocd.NewServer(ts.URL+"/go/", gocd.WithMaxBodySize(1<<20))
+	if err != nil {
+		t.Fatalf("Expected no error creating server: %s", err)
+	}
+
+	version, err := server.DetectVersion()
+	if err != nil || version.Version != "17.3.0" {
+		t.Fatalf("Expected server version, but was: %#v (%v)", version, err)
+	}
+
+	dashboard, err := server.Dashboard()
+	if err != nil || len(dashboard) != 1 || dashboard[0].Name != "Pipeline" {
+		t.Errorf("Expected legacy dashboard for an old server, but was: %#v (%v)", dashboard, err)
+	}
+
+	agents, err := server.Agents()
+	if err != nil || len(agents) != 1 || agents[0].Hostname != "agent-1" {
+		t.Errorf("Expected agents, but was: %#v (%v)", agents, err)
+	}
+
+	expected := []string{"/go/api/version", "/go/dashboard.json", "/go/api/agents"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected endpoints under the context path (%v != %v)", paths, expected)
+	}
+}
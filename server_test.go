@@ -0,0 +1,248 @@
+// server_test.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/chiku/gocd"
+)
+
+const halDashboardJSON = `{
+	"_embedded": {
+		"pipeline_groups": [{ "name": "Group", "pipelines": ["Pipeline"] }],
+		"pipelines": [{
+			"name": "Pipeline",
+			"_embedded": {
+				"instances": [{
+					"label": "2",
+					"_embedded": { "stages": [{ "name": "StageOne", "status": "Building" }] }
+				}, {
+					"label": "1",
+					"_embedded": { "stages": [{ "name": "StageOne", "status": "Failed" }] }
+				}]
+			}
+		}, {
+			"name": "Ungrouped",
+			"_embedded": { "instances": [] }
+		}]
+	}
+}`
+
+func fakeVersionedServer(version string, accepts map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accepts[r.URL.Path] = r.Header.Get("Accept")
+		switch r.URL.Path {
+		case "/go/api/version":
+			w.Write([]byte(`{
+				"version": "` + version + `",
+				"build_number": "5025",
+				"git_sha": "abc123",
+				"full_version": "` + version + ` (5025-abc123)",
+				"commit_url": "https://github.com/gocd/gocd/commits/abc123"
+			}`))
+		case "/go/api/agents":
+			w.Write([]byte(`{ "_embedded": { "agents": [] } }`))
+		case "/go/dashboard.json":
+			w.Write([]byte(`[{ "name": "Group", "pipelines": [{ "name": "Legacy", "instances": [{ "stages": [{ "name": "Stage", "status": "Passed" }] }] }] }]`))
+		case "/go/api/dashboard":
+			w.Header().Set("Content-Type", r.Header.Get("Accept")+"; charset=utf-8")
+			w.Write([]byte(halDashboardJSON))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestClientServerVersion(t *testing.T) {
+	accepts := map[string]string{}
+	ts := fakeVersionedServer("18.5.0", accepts)
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	version, err := client.ServerVersion(ts.URL)
+
+	if err != nil {
+		t.Fatalf("Expected no error fetching server version: %s", err)
+	}
+
+	expected := gocd.ServerVersion{
+		Version:     "18.5.0",
+		BuildNumber: "5025",
+		GitSHA:      "abc123",
+		FullVersion: "18.5.0 (5025-abc123)",
+		CommitURL:   "https://github.com/gocd/gocd/commits/abc123",
+	}
+	if version != expected {
+		t.Errorf("Expected proper server version (%#v != %#v)", version, expected)
+	}
+	if accepts["/go/api/version"] != "application/vnd.go.cd.v1+json" {
+		t.Errorf("Expected versioned accept header, but was: %s", accepts["/go/api/version"])
+	}
+}
+
+func TestServerVersionAtLeast(t *testing.T) {
+	version := gocd.ServerVersion{Version: "19.10.0"}
+
+	if !version.AtLeast("19.9.0") || !version.AtLeast("19.10") || version.AtLeast("19.10.1") || version.AtLeast("20.1.0") {
+		t.Errorf("Expected releases to be compared numerically for %s", version.Version)
+	}
+}
+
+func TestClientServerHealthMessages(t *testing.T) {
+	var accept string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept = r.Header.Get("Accept")
+		if r.URL.Path != "/go/api/server_health_messages" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`[{
+			"message": "GoCD Server has run out of artifacts disk space.",
+			"detail": "Scheduling has been stopped.",
+			"level": "ERROR",
+			"time": "2017-07-14T02:40:00Z"
+		}, {
+			"message": "Invalid config repo",
+			"detail": "Parse error in pipelines.gocd.yaml",
+			"level": "WARNING",
+			"time": "2017-07-14T02:41:00Z"
+		}]`))
+	}))
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	messages, err := client.ServerHealthMessages(ts.URL)
+
+	if err != nil {
+		t.Fatalf("Expected no error fetching server health messages: %s", err)
+	}
+	if accept != "application/vnd.go.cd.v1+json" {
+		t.Errorf("Expected versioned accept header, but was: %s", accept)
+	}
+
+	expected := []gocd.HealthMessage{{
+		Message: "GoCD Server has run out of artifacts disk space.",
+		Detail:  "Scheduling has been stopped.",
+		Level:   "ERROR",
+		Time:    "2017-07-14T02:40:00Z",
+	}, {
+		Message: "Invalid config repo",
+		Detail:  "Parse error in pipelines.gocd.yaml",
+		Level:   "WARNING",
+		Time:    "2017-07-14T02:41:00Z",
+	}}
+	if !reflect.DeepEqual(messages, expected) {
+		t.Errorf("Expected proper health messages (%#v != %#v)", messages, expected)
+	}
+	if !messages[0].IsError() || messages[1].IsError() {
+		t.Errorf("Expected only the first message to be an error: %#v", messages)
+	}
+}
+
+func TestClientDetectServerVersionPicksAPIVersions(t *testing.T) {
+	accepts := map[string]string{}
+	ts := fakeVersionedServer("19.4.0", accepts)
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	_, err := client.DetectServerVersion(ts.URL)
+	if err != nil {
+		t.Fatalf("Expected no error detecting server version: %s", err)
+	}
+
+	_, err = client.Agents(ts.URL)
+	if err != nil {
+		t.Fatalf("Expected no error listing agents: %s", err)
+	}
+
+	if accepts["/go/api/agents"] != "application/vnd.go.cd.v5+json" {
+		t.Errorf("Expected agents API version for 19.4.0, but was: %s", accepts["/go/api/agents"])
+	}
+}
+
+func TestClientDetectServerVersionWhileRequesting(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/go/api/version" {
+			w.Write([]byte(`{ "version": "19.4.0" }`))
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			client.DetectServerVersion(ts.URL)
+		}()
+		go func() {
+			defer wg.Done()
+			client.ServerHealthMessages(ts.URL)
+		}()
+	}
+	wg.Wait()
+
+	copied := *client
+	if _, err := copied.ServerHealthMessages(ts.URL); err != nil {
+		t.Errorf("Expected no error from a copy of the client: %s", err)
+	}
+}
+
+func TestClientFetchDashboardFromDashboardAPI(t *testing.T) {
+	accepts := map[string]string{}
+	ts := fakeVersionedServer("20.1.0", accepts)
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	dashboard, err := client.FetchDashboard(ts.URL)
+
+	if err != nil {
+		t.Fatalf("Expected no error fetching dashboard: %s", err)
+	}
+	if accepts["/go/api/dashboard"] != "application/vnd.go.cd.v3+json" {
+		t.Errorf("Expected newest dashboard API version, but was: %s", accepts["/go/api/dashboard"])
+	}
+
+	expected := gocd.Dashboard{{Name: "Pipeline", Stages: []gocd.DashboardStage{{Name: "StageOne", Status: "Building"}}}}
+	if !reflect.DeepEqual(dashboard, expected) {
+		t.Errorf("Expected dashboard from dashboard API (%#v != %#v)", dashboard, expected)
+	}
+}
+
+func TestClientFetchDashboardFromLegacyServer(t *testing.T) {
+	accepts := map[string]string{}
+	ts := fakeVersionedServer("17.3.0", accepts)
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	_, err := client.DetectServerVersion(ts.URL)
+	if err != nil {
+		t.Fatalf("Expected no error detecting server version: %s", err)
+	}
+
+	dashboard, err := client.FetchDashboard(ts.URL)
+
+	if err != nil {
+		t.Fatalf("Expected no error fetching dashboard: %s", err)
+	}
+	if _, ok := accepts["/go/api/dashboard"]; ok {
+		t.Errorf("Expected the dashboard API to be avoided on an old server")
+	}
+
+	expected := gocd.Dashboard{{Name: "Legacy", Stages: []gocd.DashboardStage{{Name: "Stage", Status: "Passed"}}}}
+	if !reflect.DeepEqual(dashboard, expected) {
+		t.Errorf("Expected dashboard from legacy JSON (%#v != %#v)", dashboard, expected)
+	}
+}
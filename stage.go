@@ -0,0 +1,67 @@
+// stage.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// StageLocator identifies a single run of a stage inside a pipeline instance.
+type StageLocator struct {
+	PipelineName    string
+	PipelineCounter int
+	StageName       string
+	StageCounter    int
+}
+
+type selectedJobs struct {
+	Jobs []string `json:"jobs"`
+}
+
+// CancelStage cancels a running stage. It returns the message reported by Gocd.
+func (c Client) CancelStage(server string, stage StageLocator) (string, error) {
+	path := fmt.Sprintf("/go/api/stages/%s/%d/%s/%d/cancel", url.PathEscape(stage.PipelineName), stage.PipelineCounter, url.PathEscape(stage.StageName), stage.StageCounter)
+	return c.operation(server, path, c.accept(stageCancelAPI), nil)
+}
+
+// RerunStage schedules a fresh run of a stage in an existing pipeline instance.
+func (c Client) RerunStage(server string, stage StageLocator) (string, error) {
+	return c.runStage(server, stage.PipelineName, stage.PipelineCounter, stage.StageName)
+}
+
+// ApproveStage triggers a stage that waits on manual approval.
+func (c Client) ApproveStage(server string, pipeline string, pipelineCounter int, stage string) (string, error) {
+	return c.runStage(server, pipeline, pipelineCounter, stage)
+}
+
+// RerunFailedJobs reruns only the failed jobs of a stage run.
+func (c Client) RerunFailedJobs(server string, stage StageLocator) (string, error) {
+	path := fmt.Sprintf("/go/api/stages/%s/%d/%s/%d/run-failed-jobs", url.PathEscape(stage.PipelineName), stage.PipelineCounter, url.PathEscape(stage.StageName), stage.StageCounter)
+	return c.operation(server, path, c.accept(stageRunAPI), nil)
+}
+
+// RerunSelectedJobs reruns the named jobs of a stage run.
+func (c Client) RerunSelectedJobs(server string, stage StageLocator, jobs []string) (string, error) {
+	if len(jobs) == 0 {
+		return "", fmt.Errorf("error rerunning jobs: no jobs selected")
+	}
+
+	payload, err := json.Marshal(selectedJobs{Jobs: jobs})
+	if err != nil {
+		return "", fmt.Errorf("error marshalling selected jobs: %s", err)
+	}
+
+	path := fmt.Sprintf("/go/api/stages/%s/%d/%s/%d/run-selected-jobs", url.PathEscape(stage.PipelineName), stage.PipelineCounter, url.PathEscape(stage.StageName), stage.StageCounter)
+	return c.operation(server, path, c.accept(stageRunAPI), payload)
+}
+
+func (c Client) runStage(server string, pipeline string, pipelineCounter int, stage string) (string, error) {
+	path := fmt.Sprintf("/go/api/stages/%s/%d/%s/run", url.PathEscape(pipeline), pipelineCounter, url.PathEscape(stage))
+	return c.operation(server, path, c.accept(stageRunAPI), nil)
+}
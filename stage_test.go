@@ -0,0 +1,198 @@
+// stage_test.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chiku/gocd"
+)
+
+type stageRequest struct {
+	method  string
+	path    string
+	accept  string
+	confirm string
+	body    string
+}
+
+func fakeStageServer(t *testing.T, received *stageRequest) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("Expected no error reading request body: %s", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		*received = stageRequest{
+			method:  r.Method,
+			path:    r.URL.EscapedPath(),
+			accept:  r.Header.Get("Accept"),
+			confirm: r.Header.Get("X-GoCD-Confirm"),
+			body:    string(body),
+		}
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"message": "Request accepted"}`))
+	}))
+}
+
+func TestClientCancelStage(t *testing.T) {
+	var received stageRequest
+	ts := fakeStageServer(t, &received)
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	stage := gocd.StageLocator{PipelineName: "Pipeline", PipelineCounter: 12, StageName: "Stage One", StageCounter: 2}
+	message, err := client.CancelStage(ts.URL+"/", stage)
+
+	if err != nil {
+		t.Fatalf("Expected no error cancelling stage: %s", err)
+	}
+	if message != "Request accepted" {
+		t.Errorf("Expected message from Gocd, but was: %s", message)
+	}
+
+	expected := stageRequest{
+		method:  "POST",
+		path:    "/go/api/stages/Pipeline/12/Stage%20One/2/cancel",
+		accept:  "application/vnd.go.cd.v3+json",
+		confirm: "true",
+	}
+	if received != expected {
+		t.Errorf("Expected proper cancel request, but was: %#v", received)
+	}
+}
+
+func TestClientRerunStage(t *testing.T) {
+	var received stageRequest
+	ts := fakeStageServer(t, &received)
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	stage := gocd.StageLocator{PipelineName: "Pipeline", PipelineCounter: 12, StageName: "Stage", StageCounter: 2}
+	_, err := client.RerunStage(ts.URL, stage)
+
+	if err != nil {
+		t.Fatalf("Expected no error rerunning stage: %s", err)
+	}
+
+	expected := stageRequest{
+		method:  "POST",
+		path:    "/go/api/stages/Pipeline/12/Stage/run",
+		accept:  "application/vnd.go.cd.v2+json",
+		confirm: "true",
+	}
+	if received != expected {
+		t.Errorf("Expected proper rerun request, but was: %#v", received)
+	}
+}
+
+func TestClientApproveStage(t *testing.T) {
+	var received stageRequest
+	ts := fakeStageServer(t, &received)
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	_, err := client.ApproveStage(ts.URL, "Pipeline", 7, "Deploy")
+
+	if err != nil {
+		t.Fatalf("Expected no error approving stage: %s", err)
+	}
+
+	expected := stageRequest{
+		method:  "POST",
+		path:    "/go/api/stages/Pipeline/7/Deploy/run",
+		accept:  "application/vnd.go.cd.v2+json",
+		confirm: "true",
+	}
+	if received != expected {
+		t.Errorf("Expected proper approval request, but was: %#v", received)
+	}
+}
+
+func TestClientRerunFailedJobs(t *testing.T) {
+	var received stageRequest
+	ts := fakeStageServer(t, &received)
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	stage := gocd.StageLocator{PipelineName: "Pipeline", PipelineCounter: 12, StageName: "Stage", StageCounter: 2}
+	_, err := client.RerunFailedJobs(ts.URL, stage)
+
+	if err != nil {
+		t.Fatalf("Expected no error rerunning failed jobs: %s", err)
+	}
+
+	expected := stageRequest{
+		method:  "POST",
+		path:    "/go/api/stages/Pipeline/12/Stage/2/run-failed-jobs",
+		accept:  "application/vnd.go.cd.v2+json",
+		confirm: "true",
+	}
+	if received != expected {
+		t.Errorf("Expected proper rerun request, but was: %#v", received)
+	}
+}
+
+func TestClientRerunSelectedJobs(t *testing.T) {
+	var received stageRequest
+	ts := fakeStageServer(t, &received)
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	stage := gocd.StageLocator{PipelineName: "Pipeline", PipelineCounter: 12, StageName: "Stage", StageCounter: 2}
+	_, err := client.RerunSelectedJobs(ts.URL, stage, []string{"unit", "lint"})
+
+	if err != nil {
+		t.Fatalf("Expected no error rerunning selected jobs: %s", err)
+	}
+
+	expected := stageRequest{
+		method:  "POST",
+		path:    "/go/api/stages/Pipeline/12/Stage/2/run-selected-jobs",
+		accept:  "application/vnd.go.cd.v2+json",
+		confirm: "true",
+		body:    `{"jobs":["unit","lint"]}`,
+	}
+	if received != expected {
+		t.Errorf("Expected proper rerun request, but was: %#v", received)
+	}
+}
+
+func TestClientRerunSelectedJobsWithoutJobs(t *testing.T) {
+	client := gocd.NewClient()
+	_, err := client.RerunSelectedJobs("http://localhost", gocd.StageLocator{}, nil)
+
+	if err == nil || err.Error() != "error rerunning jobs: no jobs selected" {
+		t.Errorf("Expected error when no jobs are selected, but was: %v", err)
+	}
+}
+
+func TestClientStageOperationWhenServerResponseNot2xx(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"message": "Stage is not running"}`))
+	}))
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	message, err := client.CancelStage(ts.URL, gocd.StageLocator{PipelineName: "Pipeline", PipelineCounter: 1, StageName: "Stage", StageCounter: 1})
+
+	if err == nil {
+		t.Fatalf("Expected error cancelling stage")
+	}
+	if !strings.Contains(err.Error(), "the HTTP status code was 409") || !strings.Contains(err.Error(), "Stage is not running") {
+		t.Errorf("Expected proper error message but was: %s", err.Error())
+	}
+	if message != "" {
+		t.Errorf("Expected no message, but was: %s", message)
+	}
+}
@@ -0,0 +1,424 @@
+// stream.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	eventHistorySize   = 256
+	defaultEventBuffer = 64
+	defaultHeartbeat   = 15 * time.Second
+
+	websocketGUID    = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	websocketText    = 0x1
+	websocketClose   = 0x8
+	websocketPing    = 0x9
+	websocketPong    = 0xA
+	websocketVersion = "13"
+	websocketTimeout = 10 * time.Second
+)
+
+// DashboardEvent is a stage status transition on a live dashboard. IDs increase by one with every event.
+type DashboardEvent struct {
+	ID             int64          `json:"id"`
+	Pipeline       string         `json:"pipeline"`
+	Stage          DashboardStage `json:"stage"`
+	PreviousStatus string         `json:"previous_status,omitempty"`
+}
+
+type streamMessage struct {
+	Type string      `json:"type"`
+	ID   int64       `json:"id"`
+	Data interface{} `json:"data"`
+}
+
+type subscription struct {
+	snapshot Dashboard
+	replay   []DashboardEvent
+	lastID   int64
+	events   chan DashboardEvent
+}
+
+// Subscribe returns the events published after this call. A subscriber that falls more than buffer
+// events behind has its channel closed, and should subscribe again. Call cancel when done.
+func (live *LiveDashboard) Subscribe(buffer int) (events <-chan DashboardEvent, cancel func()) {
+	sub := live.subscribe(-1, buffer)
+	return sub.events, func() { live.unsubscribe(sub.events) }
+}
+
+// subscribe registers a subscriber resuming after lastEventID, or starting from a snapshot when it is negative.
+// Missed events still in the history are replayed, otherwise the subscriber starts from a snapshot too.
+func (live *LiveDashboard) subscribe(lastEventID int64, buffer int) subscription {
+	if buffer <= 0 {
+		buffer = defaultEventBuffer
+	}
+
+	live.mutex.Lock()
+	defer live.mutex.Unlock()
+
+	sub := subscription{lastID: live.lastEventID, events: make(chan DashboardEvent, buffer)}
+	switch {
+	case lastEventID == live.lastEventID:
+	case lastEventID >= 0 && lastEventID < live.lastEventID && len(live.history) > 0 && lastEventID >= live.history[0].ID-1:
+		sub.replay = append(sub.replay, live.history[lastEventID-live.history[0].ID+1:]...)
+	default:
+		sub.snapshot = live.snapshot()
+	}
+
+	if live.subscribers == nil {
+		live.subscribers = map[chan DashboardEvent]bool{}
+	}
+	live.subscribers[sub.events] = true
+
+	return sub
+}
+
+func (live *LiveDashboard) unsubscribe(events chan DashboardEvent) {
+	live.mutex.Lock()
+	defer live.mutex.Unlock()
+
+	if live.subscribers[events] {
+		delete(live.subscribers, events)
+		close(events)
+	}
+}
+
+// publish must be called with the mutex held for writing.
+func (live *LiveDashboard) publish(event DashboardEvent) {
+	live.lastEventID++
+	event.ID = live.lastEventID
+
+	live.history = append(live.history, event)
+	if len(live.history) > eventHistorySize {
+		live.history = live.history[len(live.history)-eventHistorySize:]
+	}
+
+	for events := range live.subscribers {
+		select {
+		case events <- event:
+		default:
+			delete(live.subscribers, events)
+			close(events)
+		}
+	}
+}
+
+// snapshot must be called with the mutex held.
+func (live *LiveDashboard) snapshot() Dashboard {
+	dashboard := Dashboard{}
+	for _, pipeline := range live.pipelines {
+		snapshot := pipeline.DashboardPipeline
+		snapshot.Stages = append([]DashboardStage{}, pipeline.Stages...)
+		dashboard = append(dashboard, snapshot)
+	}
+
+	return dashboard
+}
+
+// DashboardStream pushes a live dashboard to browsers, as server-sent events or over a WebSocket.
+// Clients get the full dashboard on connect and then stage transitions. Reconnecting SSE clients
+// sending Last-Event-ID get the transitions they missed, or the full dashboard when too many were missed.
+// Clients too slow to keep up with Buffer events are disconnected, so that they reconnect.
+// CheckOrigin tells if a browser page at the Origin of a WebSocket request may connect. By default only
+// pages served from the same host, and clients sending no Origin, may connect, so that other websites
+// cannot read the dashboard through the browsers of its visitors.
+type DashboardStream struct {
+	Dashboard   *LiveDashboard
+	Heartbeat   time.Duration
+	Buffer      int
+	CheckOrigin func(r *http.Request) bool
+}
+
+func (stream DashboardStream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		stream.serveWebSocket(w, r)
+		return
+	}
+
+	stream.serveEvents(w, r)
+}
+
+func (stream DashboardStream) heartbeat() time.Duration {
+	if stream.Heartbeat <= 0 {
+		return defaultHeartbeat
+	}
+
+	return stream.Heartbeat
+}
+
+func (stream DashboardStream) allowOrigin(r *http.Request) bool {
+	if stream.CheckOrigin != nil {
+		return stream.CheckOrigin(r)
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	parsed, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(parsed.Host, r.Host)
+}
+
+func (stream DashboardStream) connect(lastEventID int64) ([]streamMessage, subscription) {
+	sub := stream.Dashboard.subscribe(lastEventID, stream.Buffer)
+
+	var messages []streamMessage
+	if sub.snapshot != nil {
+		messages = append(messages, streamMessage{Type: "dashboard", ID: sub.lastID, Data: sub.snapshot})
+	}
+	for _, event := range sub.replay {
+		messages = append(messages, streamMessage{Type: "stage", ID: event.ID, Data: event})
+	}
+
+	return messages, sub
+}
+
+func (stream DashboardStream) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	lastEventID := int64(-1)
+	if header := r.Header.Get("Last-Event-ID"); header != "" {
+		if id, err := strconv.ParseInt(header, 10, 64); err == nil && id >= 0 {
+			lastEventID = id
+		}
+	}
+
+	messages, sub := stream.connect(lastEventID)
+	defer stream.Dashboard.unsubscribe(sub.events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	fmt.Fprintf(w, "retry: %d\n\n", 3000)
+	for _, message := range messages {
+		if writeEvent(w, message) != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(stream.heartbeat())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-sub.events:
+			if !ok {
+				return
+			}
+			if writeEvent(w, streamMessage{Type: "stage", ID: event.ID, Data: event}) != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent(w io.Writer, message streamMessage) error {
+	data, err := json.Marshal(message.Data)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", message.ID, message.Type, data)
+	return err
+}
+
+func (stream DashboardStream) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" || !strings.Contains(strings.ToLower(r.Header.Get("Connection")), "upgrade") {
+		http.Error(w, "invalid WebSocket handshake", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(r.Header.Get("Sec-WebSocket-Version")) != websocketVersion {
+		w.Header().Set("Sec-WebSocket-Version", websocketVersion)
+		http.Error(w, "unsupported WebSocket version", http.StatusUpgradeRequired)
+		return
+	}
+	if !stream.allowOrigin(r) {
+		http.Error(w, "WebSocket origin not allowed", http.StatusForbidden)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	conn, buffered, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	accept := sha1.Sum([]byte(key + websocketGUID))
+	fmt.Fprintf(buffered, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(accept[:]))
+
+	messages, sub := stream.connect(-1)
+	defer stream.Dashboard.unsubscribe(sub.events)
+
+	var mutex sync.Mutex
+	write := func(opcode byte, payload []byte) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		conn.SetWriteDeadline(time.Now().Add(websocketTimeout))
+		err := writeWebSocketFrame(buffered.Writer, opcode, payload)
+		if err == nil {
+			err = buffered.Flush()
+		}
+		return err
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		readWebSocketUntilClose(buffered.Reader, func(payload []byte) {
+			write(websocketPong, payload)
+		})
+	}()
+
+	writeMessage := func(message streamMessage) error {
+		data, err := json.Marshal(message)
+		if err != nil {
+			return err
+		}
+		return write(websocketText, data)
+	}
+
+	for _, message := range messages {
+		if writeMessage(message) != nil {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(stream.heartbeat())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-closed:
+			write(websocketClose, nil)
+			return
+		case <-ticker.C:
+			if write(websocketPing, nil) != nil {
+				return
+			}
+		case event, ok := <-sub.events:
+			if !ok {
+				write(websocketClose, nil)
+				return
+			}
+			if writeMessage(streamMessage{Type: "stage", ID: event.ID, Data: event}) != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeWebSocketFrame(w io.Writer, opcode byte, payload []byte) error {
+	header := []byte{0x80 | opcode}
+	switch length := len(payload); {
+	case length < 126:
+		header = append(header, byte(length))
+	case length <= 0xFFFF:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// readWebSocketUntilClose discards client frames until the client closes the connection,
+// handing the payload of pings to ping for a reply.
+func readWebSocketUntilClose(r *bufio.Reader, ping func(payload []byte)) {
+	header := make([]byte, 2)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return
+		}
+		opcode := header[0] & 0x0F
+		if opcode == websocketClose {
+			return
+		}
+
+		length := uint64(header[1] & 0x7F)
+		switch length {
+		case 126:
+			extended := make([]byte, 2)
+			if _, err := io.ReadFull(r, extended); err != nil {
+				return
+			}
+			length = uint64(binary.BigEndian.Uint16(extended))
+		case 127:
+			extended := make([]byte, 8)
+			if _, err := io.ReadFull(r, extended); err != nil {
+				return
+			}
+			length = binary.BigEndian.Uint64(extended)
+		}
+		mask := make([]byte, 4)
+		if header[1]&0x80 != 0 {
+			if _, err := io.ReadFull(r, mask); err != nil {
+				return
+			}
+		}
+
+		if opcode != websocketPing || length > 125 {
+			if _, err := io.CopyN(ioutil.Discard, r, int64(length)); err != nil {
+				return
+			}
+			continue
+		}
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return
+		}
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+		ping(payload)
+	}
+}
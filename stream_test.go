@@ -0,0 +1,308 @@
+// stream_test.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd_test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chiku/gocd"
+)
+
+type serverSentEvent struct {
+	id    string
+	event string
+	data  string
+}
+
+func readServerSentEvent(t *testing.T, reader *bufio.Reader) serverSentEvent {
+	var event serverSentEvent
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Expected no error reading event stream: %s", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "" && (event.event != "" || event.data != ""):
+			return event
+		case strings.HasPrefix(line, "id: "):
+			event.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			event.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			event.data = strings.TrimPrefix(line, "data: ")
+		case strings.HasPrefix(line, ": "):
+			event.event = "comment"
+			event.data = strings.TrimPrefix(line, ": ")
+		}
+	}
+}
+
+func openEventStream(t *testing.T, url string, lastEventID string) (*bufio.Reader, func()) {
+	request, _ := http.NewRequest("GET", url, nil)
+	if lastEventID != "" {
+		request.Header.Set("Last-Event-ID", lastEventID)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("Expected no error connecting to event stream: %s", err)
+	}
+	if response.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected event stream, but was: %s", response.Header.Get("Content-Type"))
+	}
+	return bufio.NewReader(response.Body), func() { response.Body.Close() }
+}
+
+func buildNotification(counter int, state string) gocd.StageNotification {
+	return gocd.StageNotification{Pipeline: "Build", PipelineCounter: counter, Stage: "Test", State: state, Result: state}
+}
+
+func TestDashboardStreamSendsDashboardThenTransitions(t *testing.T) {
+	live := gocd.NewLiveDashboard(gocd.Dashboard{{Name: "Build", Stages: []gocd.DashboardStage{{Name: "Test", Status: "Passed"}}}})
+	ts := httptest.NewServer(gocd.DashboardStream{Dashboard: live, Heartbeat: time.Hour})
+	defer ts.Close()
+
+	reader, disconnect := openEventStream(t, ts.URL, "")
+	defer disconnect()
+
+	event := readServerSentEvent(t, reader)
+	if event.event != "dashboard" || event.id != "0" || event.data != `[{"name":"Build","stages":[{"name":"Test","status":"Passed"}]}]` {
+		t.Errorf("Expected full dashboard on connect, but was: %#v", event)
+	}
+
+	live.Update(buildNotification(2, "Building"))
+	event = readServerSentEvent(t, reader)
+	if event.event != "stage" || event.id != "1" || event.data != `{"id":1,"pipeline":"Build","stage":{"name":"Test","status":"Building"},"previous_status":"Passed"}` {
+		t.Errorf("Expected stage transition, but was: %#v", event)
+	}
+}
+
+func TestDashboardStreamResumesFromLastEventID(t *testing.T) {
+	live := gocd.NewLiveDashboard(nil)
+	live.Update(buildNotification(1, "Building"))
+	live.Update(buildNotification(1, "Passed"))
+	live.Update(buildNotification(2, "Building"))
+	ts := httptest.NewServer(gocd.DashboardStream{Dashboard: live, Heartbeat: time.Hour})
+	defer ts.Close()
+
+	reader, disconnect := openEventStream(t, ts.URL, "1")
+	defer disconnect()
+
+	for _, expectedID := range []string{"2", "3"} {
+		event := readServerSentEvent(t, reader)
+		if event.event != "stage" || event.id != expectedID {
+			t.Errorf("Expected missed transition %s, but was: %#v", expectedID, event)
+		}
+	}
+}
+
+func TestDashboardStreamSendsDashboardWhenLastEventIDIsUnknown(t *testing.T) {
+	live := gocd.NewLiveDashboard(nil)
+	live.Update(buildNotification(1, "Passed"))
+	ts := httptest.NewServer(gocd.DashboardStream{Dashboard: live, Heartbeat: time.Hour})
+	defer ts.Close()
+
+	reader, disconnect := openEventStream(t, ts.URL, "99")
+	defer disconnect()
+
+	event := readServerSentEvent(t, reader)
+	if event.event != "dashboard" || event.id != "1" {
+		t.Errorf("Expected full dashboard for an unknown event ID, but was: %#v", event)
+	}
+}
+
+func TestDashboardStreamSendsHeartbeats(t *testing.T) {
+	live := gocd.NewLiveDashboard(nil)
+	ts := httptest.NewServer(gocd.DashboardStream{Dashboard: live, Heartbeat: 10 * time.Millisecond})
+	defer ts.Close()
+
+	reader, disconnect := openEventStream(t, ts.URL, "")
+	defer disconnect()
+
+	readServerSentEvent(t, reader)
+	event := readServerSentEvent(t, reader)
+	if event.event != "comment" || event.data != "heartbeat" {
+		t.Errorf("Expected heartbeat, but was: %#v", event)
+	}
+}
+
+func TestLiveDashboardDropsSlowSubscribers(t *testing.T) {
+	live := gocd.NewLiveDashboard(nil)
+	events, cancel := live.Subscribe(1)
+	defer cancel()
+
+	live.Update(buildNotification(1, "Building"))
+	live.Update(buildNotification(1, "Passed"))
+
+	event, ok := <-events
+	if !ok || event.ID != 1 {
+		t.Errorf("Expected first event to be delivered, but was: %#v", event)
+	}
+	if _, ok = <-events; ok {
+		t.Errorf("Expected slow subscriber to be dropped")
+	}
+}
+
+func readWebSocketFrame(t *testing.T, reader *bufio.Reader) (byte, string) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		t.Fatalf("Expected no error reading WebSocket frame: %s", err)
+	}
+	length := int(header[1] & 0x7F)
+	if length == 126 {
+		extended := make([]byte, 2)
+		io.ReadFull(reader, extended)
+		length = int(binary.BigEndian.Uint16(extended))
+	}
+	payload := make([]byte, length)
+	io.ReadFull(reader, payload)
+	return header[0] & 0x0F, string(payload)
+}
+
+func TestDashboardStreamOverWebSocket(t *testing.T) {
+	live := gocd.NewLiveDashboard(gocd.Dashboard{{Name: "Build", Stages: []gocd.DashboardStage{{Name: "Test", Status: "Passed"}}}})
+	ts := httptest.NewServer(gocd.DashboardStream{Dashboard: live, Heartbeat: time.Hour})
+	defer ts.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(ts.URL, "http://"))
+	if err != nil {
+		t.Fatalf("Expected no error connecting: %s", err)
+	}
+	defer conn.Close()
+
+	fmt.Fprintf(conn, "GET / HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n")
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("Expected no error reading handshake: %s", err)
+	}
+	if response.StatusCode != http.StatusSwitchingProtocols || response.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Expected WebSocket handshake, but was: %d %v", response.StatusCode, response.Header)
+	}
+
+	opcode, payload := readWebSocketFrame(t, reader)
+	if opcode != 0x1 || payload != `{"type":"dashboard","id":0,"data":[{"name":"Build","stages":[{"name":"Test","status":"Passed"}]}]}` {
+		t.Errorf("Expected full dashboard on connect, but was: %d %s", opcode, payload)
+	}
+
+	live.Update(buildNotification(2, "Failed"))
+	opcode, payload = readWebSocketFrame(t, reader)
+	if opcode != 0x1 || payload != `{"type":"stage","id":1,"data":{"id":1,"pipeline":"Build","stage":{"name":"Test","status":"Failed"},"previous_status":"Passed"}}` {
+		t.Errorf("Expected stage transition, but was: %d %s", opcode, payload)
+	}
+
+	conn.Write([]byte{0x88, 0x80, 0, 0, 0, 0})
+	opcode, _ = readWebSocketFrame(t, reader)
+	if opcode != 0x8 {
+		t.Errorf("Expected close frame in reply, but was: %d", opcode)
+	}
+}
+
+func webSocketHandshake(t *testing.T, url string, headers string) (net.Conn, *bufio.Reader, *http.Response) {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		t.Fatalf("Expected no error connecting: %s", err)
+	}
+
+	fmt.Fprintf(conn, "GET / HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n%s\r\n",
+		strings.TrimPrefix(url, "http://"), headers)
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("Expected no error reading handshake: %s", err)
+	}
+
+	return conn, reader, response
+}
+
+func TestDashboardStreamOverWebSocketChecksOrigin(t *testing.T) {
+	live := gocd.NewLiveDashboard(nil)
+	ts := httptest.NewServer(gocd.DashboardStream{Dashboard: live, Heartbeat: time.Hour})
+	defer ts.Close()
+
+	conn, _, response := webSocketHandshake(t, ts.URL, "Sec-WebSocket-Version: 13\r\nOrigin: http://evil.example.com\r\n")
+	conn.Close()
+	if response.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected cross-site WebSocket to be forbidden, but was: %d", response.StatusCode)
+	}
+
+	conn, _, response = webSocketHandshake(t, ts.URL, "Sec-WebSocket-Version: 13\r\nOrigin: "+ts.URL+"\r\n")
+	conn.Close()
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("Expected same origin WebSocket to be accepted, but was: %d", response.StatusCode)
+	}
+}
+
+func TestDashboardStreamOverWebSocketWithCheckOrigin(t *testing.T) {
+	live := gocd.NewLiveDashboard(nil)
+	checkOrigin := func(r *http.Request) bool {
+		return r.Header.Get("Origin") == "https://wallboard.example.com"
+	}
+	ts := httptest.NewServer(gocd.DashboardStream{Dashboard: live, Heartbeat: time.Hour, CheckOrigin: checkOrigin})
+	defer ts.Close()
+
+	conn, _, response := webSocketHandshake(t, ts.URL, "Sec-WebSocket-Version: 13\r\nOrigin: https://wallboard.example.com\r\n")
+	conn.Close()
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("Expected allowed origin to be accepted, but was: %d", response.StatusCode)
+	}
+
+	conn, _, response = webSocketHandshake(t, ts.URL, "Sec-WebSocket-Version: 13\r\nOrigin: "+ts.URL+"\r\n")
+	conn.Close()
+	if response.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected origin rejected by CheckOrigin to be forbidden, but was: %d", response.StatusCode)
+	}
+}
+
+func TestDashboardStreamOverWebSocketRejectsUnsupportedVersion(t *testing.T) {
+	live := gocd.NewLiveDashboard(nil)
+	ts := httptest.NewServer(gocd.DashboardStream{Dashboard: live, Heartbeat: time.Hour})
+	defer ts.Close()
+
+	for _, headers := range []string{"Sec-WebSocket-Version: 8\r\n", ""} {
+		conn, _, response := webSocketHandshake(t, ts.URL, headers)
+		conn.Close()
+		if response.StatusCode != http.StatusUpgradeRequired || response.Header.Get("Sec-WebSocket-Version") != "13" {
+			t.Errorf("Expected unsupported version to be rejected for %q, but was: %d %v", headers, response.StatusCode, response.Header)
+		}
+	}
+}
+
+func TestDashboardStreamOverWebSocketAnswersPings(t *testing.T) {
+	live := gocd.NewLiveDashboard(nil)
+	ts := httptest.NewServer(gocd.DashboardStream{Dashboard: live, Heartbeat: time.Hour})
+	defer ts.Close()
+
+	conn, reader, response := webSocketHandshake(t, ts.URL, "Sec-WebSocket-Version: 13\r\n")
+	defer conn.Close()
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected WebSocket handshake, but was: %d", response.StatusCode)
+	}
+	readWebSocketFrame(t, reader)
+
+	mask := []byte{1, 2, 3, 4}
+	payload := []byte("hi")
+	frame := append([]byte{0x89, 0x80 | byte(len(payload))}, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	conn.Write(frame)
+
+	opcode, pong := readWebSocketFrame(t, reader)
+	if opcode != 0xA || pong != "hi" {
+		t.Errorf("Expected pong with the ping payload, but was: %d %q", opcode, pong)
+	}
+}
@@ -0,0 +1,97 @@
+// tls.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// WithRootCAs makes the client trust servers signed by the certificate authorities in pool, instead of
+// those of the system. Use CertPoolFromPEM or CertPoolFromFile for an internal certificate authority.
+func WithRootCAs(pool *x509.CertPool) Option {
+	return withTLS(func(config *tls.Config) {
+		config.RootCAs = pool
+	})
+}
+
+// WithClientCertificate makes the client present certificate to servers asking for one, as done with mutual TLS.
+// Load it with tls.LoadX509KeyPair or tls.X509KeyPair.
+func WithClientCertificate(certificate tls.Certificate) Option {
+	return withTLS(func(config *tls.Config) {
+		config.Certificates = append(config.Certificates, certificate)
+	})
+}
+
+// WithMinTLSVersion refuses servers that do not support at least version, such as tls.VersionTLS12.
+func WithMinTLSVersion(version uint16) Option {
+	return withTLS(func(config *tls.Config) {
+		config.MinVersion = version
+	})
+}
+
+// WithPinnedPublicKeys refuses servers unless a certificate in their chain has one of the public keys
+// pinned, on top of the usual certificate checks. Pins are base64 encoded SHA-256 hashes of the subject
+// public key info, with an optional "sha256/" prefix, as computed by PublicKeyPin.
+func WithPinnedPublicKeys(pins ...string) Option {
+	pinned := map[string]bool{}
+	for _, pin := range pins {
+		pinned[strings.TrimPrefix(strings.TrimSpace(pin), "sha256/")] = true
+	}
+
+	return withTLS(func(config *tls.Config) {
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			for _, certificate := range state.PeerCertificates {
+				if pinned[strings.TrimPrefix(PublicKeyPin(certificate), "sha256/")] {
+					return nil
+				}
+			}
+			return fmt.Errorf("error verifying Gocd server: no pinned public key in its certificates")
+		}
+	})
+}
+
+// PublicKeyPin is the pin of the public key of certificate, for use with WithPinnedPublicKeys.
+func PublicKeyPin(certificate *x509.Certificate) string {
+	hash := sha256.Sum256(certificate.RawSubjectPublicKeyInfo)
+	return "sha256/" + base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// CertPoolFromPEM reads the PEM encoded certificates of certificate authorities.
+func CertPoolFromPEM(pem []byte) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("error reading CA certificates: no certificate found in PEM")
+	}
+
+	return pool, nil
+}
+
+// CertPoolFromFile reads the PEM encoded certificates of certificate authorities from a file.
+func CertPoolFromFile(path string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading CA certificates: %s", err)
+	}
+
+	return CertPoolFromPEM(pem)
+}
+
+func withTLS(option func(*tls.Config)) Option {
+	return withTransport(func(transport *http.Transport) {
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		option(transport.TLSClientConfig)
+	})
+}
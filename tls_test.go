@@ -0,0 +1,235 @@
+// tls_test.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chiku/gocd"
+)
+
+func fakeTLSServer() *httptest.Server {
+	return httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	}))
+}
+
+func serverCertificatePEM(ts *httptest.Server) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+}
+
+func clientCertificate(t *testing.T) (tls.Certificate, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Expected no error generating key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "gocd-client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Expected no error creating certificate: %s", err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+
+	certificate, err := tls.X509KeyPair(
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	)
+	if err != nil {
+		t.Fatalf("Expected no error loading key pair: %s", err)
+	}
+	parsed, _ := x509.ParseCertificate(der)
+	return certificate, parsed
+}
+
+func TestClientWithRootCAs(t *testing.T) {
+	ts := fakeTLSServer()
+	ts.StartTLS()
+	defer ts.Close()
+
+	_, err := gocd.NewClient().Fetch(ts.URL)
+	if err == nil || !strings.Contains(err.Error(), "certificate") {
+		t.Errorf("Expected error about unknown certificate authority, but was: %v", err)
+	}
+
+	pool, err := gocd.CertPoolFromPEM(serverCertificatePEM(ts))
+	if err != nil {
+		t.Fatalf("Expected no error reading CA certificate: %s", err)
+	}
+	_, err = gocd.NewClient(gocd.WithRootCAs(pool)).Fetch(ts.URL)
+	if err != nil {
+		t.Errorf("Expected no error with the CA of the server: %s", err)
+	}
+}
+
+func TestCertPoolFromFile(t *testing.T) {
+	ts := fakeTLSServer()
+	ts.StartTLS()
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "gocd")
+	if err != nil {
+		t.Fatalf("Expected no error creating directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ca.pem")
+	ioutil.WriteFile(path, serverCertificatePEM(ts), 0600)
+
+	pool, err := gocd.CertPoolFromFile(path)
+	if err != nil {
+		t.Fatalf("Expected no error reading CA file: %s", err)
+	}
+	_, err = gocd.NewClient(gocd.WithRootCAs(pool)).Fetch(ts.URL)
+	if err != nil {
+		t.Errorf("Expected no error with the CA of the server: %s", err)
+	}
+
+	_, err = gocd.CertPoolFromFile(filepath.Join(dir, "missing.pem"))
+	if err == nil || !strings.Contains(err.Error(), "error reading CA certificates: ") {
+		t.Errorf("Expected error about missing file, but was: %v", err)
+	}
+}
+
+func TestCertPoolFromPEMOnError(t *testing.T) {
+	_, err := gocd.CertPoolFromPEM([]byte("Random"))
+
+	if err == nil || err.Error() != "error reading CA certificates: no certificate found in PEM" {
+		t.Errorf("Expected error about missing certificate, but was: %v", err)
+	}
+}
+
+func TestClientWithClientCertificate(t *testing.T) {
+	certificate, parsed := clientCertificate(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(parsed)
+
+	ts := fakeTLSServer()
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	ts.StartTLS()
+	defer ts.Close()
+	pool, _ := gocd.CertPoolFromPEM(serverCertificatePEM(ts))
+
+	_, err := gocd.NewClient(gocd.WithRootCAs(pool)).Fetch(ts.URL)
+	if err == nil {
+		t.Errorf("Expected error without client certificate")
+	}
+
+	_, err = gocd.NewClient(gocd.WithRootCAs(pool), gocd.WithClientCertificate(certificate)).Fetch(ts.URL)
+	if err != nil {
+		t.Errorf("Expected no error with client certificate: %s", err)
+	}
+}
+
+func TestClientWithMinTLSVersion(t *testing.T) {
+	ts := fakeTLSServer()
+	ts.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	ts.StartTLS()
+	defer ts.Close()
+	pool, _ := gocd.CertPoolFromPEM(serverCertificatePEM(ts))
+
+	_, err := gocd.NewClient(gocd.WithRootCAs(pool), gocd.WithMinTLSVersion(tls.VersionTLS12)).Fetch(ts.URL)
+	if err != nil {
+		t.Errorf("Expected no error with TLS 1.2: %s", err)
+	}
+
+	_, err = gocd.NewClient(gocd.WithRootCAs(pool), gocd.WithMinTLSVersion(tls.VersionTLS13)).Fetch(ts.URL)
+	if err == nil || !strings.Contains(err.Error(), "protocol version") {
+		t.Errorf("Expected error about TLS version, but was: %v", err)
+	}
+}
+
+func TestClientWithPinnedPublicKeys(t *testing.T) {
+	ts := fakeTLSServer()
+	ts.StartTLS()
+	defer ts.Close()
+	pool, _ := gocd.CertPoolFromPEM(serverCertificatePEM(ts))
+	_, other := clientCertificate(t)
+
+	_, err := gocd.NewClient(gocd.WithRootCAs(pool), gocd.WithPinnedPublicKeys(gocd.PublicKeyPin(other), gocd.PublicKeyPin(ts.Certificate()))).Fetch(ts.URL)
+	if err != nil {
+		t.Errorf("Expected no error with pinned key: %s", err)
+	}
+
+	_, err = gocd.NewClient(gocd.WithRootCAs(pool), gocd.WithPinnedPublicKeys(strings.TrimPrefix(gocd.PublicKeyPin(other), "sha256/"))).Fetch(ts.URL)
+	if err == nil || !strings.Contains(err.Error(), "error verifying Gocd server: no pinned public key in its certificates") {
+		t.Errorf("Expected error about pinned keys, but was: %v", err)
+	}
+}
+
+func TestClientWithPinnedPublicKeysAndTransportWrapper(t *testing.T) {
+	ts := fakeTLSServer()
+	ts.StartTLS()
+	defer ts.Close()
+	pool, _ := gocd.CertPoolFromPEM(serverCertificatePEM(ts))
+	_, other := clientCertificate(t)
+
+	requests := 0
+	logging := func(base http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+			requests++
+			return base.RoundTrip(request)
+		})
+	}
+
+	client := gocd.NewClient(gocd.WithTransportWrapper(logging), gocd.WithRootCAs(pool), gocd.WithPinnedPublicKeys(gocd.PublicKeyPin(other)))
+	_, err := client.Fetch(ts.URL)
+	if err == nil || !strings.Contains(err.Error(), "error verifying Gocd server: no pinned public key in its certificates") {
+		t.Errorf("Expected error about pinned keys through the wrapper, but was: %v", err)
+	}
+	if requests == 0 {
+		t.Errorf("Expected requests to go through the wrapper")
+	}
+}
+
+func TestClientWithPinnedPublicKeysAndCustomTransport(t *testing.T) {
+	requests := 0
+	transport := roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+		requests++
+		return fakeResponse(request, http.StatusOK, `[]`), nil
+	})
+	options := []gocd.Option{gocd.WithTransport(transport), gocd.WithPinnedPublicKeys("sha256/AAAA")}
+	message := "proxy, Unix socket and TLS options need an *http.Transport, but the transport was gocd_test.roundTripperFunc; use WithTransportWrapper to wrap it instead"
+
+	_, err := gocd.NewClient(options...).Fetch("http://gocd/go/dashboard.json")
+	if err == nil || !strings.Contains(err.Error(), message) {
+		t.Errorf("Expected error combining TLS options with a custom transport, but was: %v", err)
+	}
+	if requests != 0 {
+		t.Errorf("Expected no request through the custom transport, but was: %d", requests)
+	}
+
+	server, err := gocd.NewServer("http://gocd", options...)
+	if err == nil || err.Error() != "error configuring Gocd client: "+message {
+		t.Errorf("Expected error creating a server with TLS options and a custom transport, but was: %v", err)
+	}
+	if server != nil {
+		t.Errorf("Expected no server, but was: %#v", server)
+	}
+}
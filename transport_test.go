@@ -0,0 +1,142 @@
+// transport_test.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chiku/gocd"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
+
+func fakeResponse(request *http.Request, status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+		Request:    request,
+	}
+}
+
+func TestClientWithTransportRetries(t *testing.T) {
+	attempts := 0
+	transport := roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+		attempts++
+		if attempts < 3 {
+			return nil, fmt.Errorf("connection reset")
+		}
+		return fakeResponse(request, http.StatusOK, `[]`), nil
+	})
+
+	dashboard, err := gocd.NewClient(gocd.WithTransport(transport)).Fetch("http://gocd.example.com/go/dashboard.json")
+
+	if err != nil || dashboard == nil || attempts != 3 {
+		t.Errorf("Expected dashboard after retries, but was: %#v (error: %v, attempts: %d)", dashboard, err, attempts)
+	}
+}
+
+func TestClientWithTransportChecksAuthentication(t *testing.T) {
+	transport := roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+		return fakeResponse(request, http.StatusUnauthorized, `Unauthorized`), nil
+	})
+
+	_, err := gocd.NewClient(gocd.WithTransport(transport)).Agents("http://gocd.example.com")
+
+	var authErr *gocd.AuthError
+	if !errors.As(err, &authErr) || authErr.URL != "http://gocd.example.com/go/api/agents" {
+		t.Errorf("Expected authentication error, but was: %v", err)
+	}
+}
+
+func TestClientWithHTTPClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	}))
+	defer ts.Close()
+
+	transport := &http.Transport{}
+	httpClient := &http.Client{Transport: transport}
+	proxyURL, _ := url.Parse(ts.URL)
+
+	_, err := gocd.NewClient(gocd.WithHTTPClient(httpClient), gocd.WithProxy(proxyURL)).Fetch("http://gocd.example.com/go/dashboard.json")
+
+	if err != nil {
+		t.Errorf("Expected no error with the given client: %s", err)
+	}
+	if httpClient.Transport != transport || transport.Proxy != nil {
+		t.Errorf("Expected the given client to be left untouched, but was: %#v", httpClient)
+	}
+}
+
+func TestClientWithProxy(t *testing.T) {
+	var requested, authorization string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested, authorization = r.URL.String(), r.Header.Get("Proxy-Authorization")
+		w.Write([]byte(`[]`))
+	}))
+	defer proxy.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL)
+	proxyURL.User = url.UserPassword("user", "secret")
+	_, err := gocd.NewClient(gocd.WithProxy(proxyURL)).Fetch("http://gocd.example.com/go/dashboard.json")
+
+	if err != nil {
+		t.Fatalf("Expected no error through proxy: %s", err)
+	}
+	if requested != "http://gocd.example.com/go/dashboard.json" {
+		t.Errorf("Expected request for Gocd through proxy, but was: %s", requested)
+	}
+	if authorization != "Basic "+base64.StdEncoding.EncodeToString([]byte("user:secret")) {
+		t.Errorf("Expected proxy credentials, but was: %s", authorization)
+	}
+}
+
+func TestClientWithUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gocd")
+	if err != nil {
+		t.Fatalf("Expected no error creating directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "gocd.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("Unix sockets are not available: %s", err)
+	}
+	var host string
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+		w.Write([]byte(`[{ "name": "Group", "pipelines": [{ "name": "Pipeline", "instances": [{ "stages": [{ "name": "Stage", "status": "Passed" }] }] }] }]`))
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	dashboard, err := gocd.NewClient(gocd.WithUnixSocket(socket)).Fetch("http://gocd.internal/go/dashboard.json")
+
+	if err != nil || len(dashboard) != 1 || dashboard[0].Name != "Pipeline" {
+		t.Errorf("Expected dashboard over Unix socket, but was: %#v (%v)", dashboard, err)
+	}
+	if host != "gocd.internal" {
+		t.Errorf("Expected host from the URL, but was: %s", host)
+	}
+}
@@ -0,0 +1,210 @@
+// value_stream_map.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+type ValueStreamStage struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+type ValueStreamInstance struct {
+	Counter int                `json:"counter"`
+	Label   string             `json:"label"`
+	Stages  []ValueStreamStage `json:"stages"`
+}
+type ValueStreamModification struct {
+	Revision     string `json:"revision"`
+	User         string `json:"user"`
+	Comment      string `json:"comment"`
+	ModifiedTime string `json:"modified_time"`
+}
+type ValueStreamRevision struct {
+	Modifications []ValueStreamModification `json:"modifications"`
+}
+type ValueStreamNode struct {
+	ID                string                `json:"id"`
+	Name              string                `json:"name"`
+	Type              string                `json:"node_type"`
+	Depth             int                   `json:"depth"`
+	Parents           []string              `json:"parents"`
+	Dependents        []string              `json:"dependents"`
+	Instances         []ValueStreamInstance `json:"instances"`
+	MaterialRevisions []ValueStreamRevision `json:"material_revisions"`
+}
+type ValueStreamEdge struct {
+	From string
+	To   string
+}
+
+// ValueStreamMap is the graph of materials and pipelines that an upstream pipeline instance flowed through.
+// Nodes are in level order, from materials to the furthest downstream pipelines.
+type ValueStreamMap struct {
+	CurrentPipeline string
+	Nodes           []ValueStreamNode
+	Edges           []ValueStreamEdge
+	index           map[string]int
+}
+type valueStreamMapResponse struct {
+	CurrentPipeline string `json:"current_pipeline"`
+	Levels          []struct {
+		Nodes []ValueStreamNode `json:"nodes"`
+	} `json:"levels"`
+	Error string `json:"error"`
+}
+
+// NewValueStreamMap builds a value stream map from the JSON Gocd serves for it.
+func NewValueStreamMap(body []byte) (ValueStreamMap, error) {
+	var response valueStreamMapResponse
+	err := json.Unmarshal(body, &response)
+	if err != nil {
+		return ValueStreamMap{}, fmt.Errorf("error unmarshalling Gocd JSON: %s", err)
+	}
+	if response.Error != "" {
+		return ValueStreamMap{}, fmt.Errorf("error fetching value stream map: %s", response.Error)
+	}
+
+	vsm := ValueStreamMap{CurrentPipeline: response.CurrentPipeline, index: map[string]int{}}
+	for _, level := range response.Levels {
+		for _, node := range level.Nodes {
+			vsm.index[node.ID] = len(vsm.Nodes)
+			vsm.Nodes = append(vsm.Nodes, node)
+			for _, dependent := range node.Dependents {
+				vsm.Edges = append(vsm.Edges, ValueStreamEdge{From: node.ID, To: dependent})
+			}
+		}
+	}
+
+	return vsm, nil
+}
+
+// ValueStreamMap fetches the value stream map of a pipeline instance.
+func (c Client) ValueStreamMap(server string, pipeline string, counter int) (ValueStreamMap, error) {
+	path := fmt.Sprintf("/go/pipelines/value_stream_map/%s/%d.json", url.PathEscape(pipeline), counter)
+	request, err := newGocdRequest("GET", strings.TrimRight(server, "/")+path, "application/json", nil)
+	if err != nil {
+		return ValueStreamMap{}, err
+	}
+
+	body, err := c.receiveJSON(request)
+	if err != nil {
+		return ValueStreamMap{}, err
+	}
+
+	return NewValueStreamMap(body)
+}
+
+// Node finds a node by its ID. Pipeline nodes are identified by the pipeline name.
+func (vsm ValueStreamMap) Node(id string) (ValueStreamNode, bool) {
+	i, ok := vsm.index[id]
+	if !ok {
+		return ValueStreamNode{}, false
+	}
+
+	return vsm.Nodes[i], true
+}
+
+// Downstream returns every node reachable from the given node through its dependents, nearest first.
+func (vsm ValueStreamMap) Downstream(id string) (downstream []ValueStreamNode) {
+	return vsm.traverse(id, func(node ValueStreamNode) []string { return node.Dependents })
+}
+
+// Upstream returns every node the given node depends on, nearest first.
+func (vsm ValueStreamMap) Upstream(id string) (upstream []ValueStreamNode) {
+	return vsm.traverse(id, func(node ValueStreamNode) []string { return node.Parents })
+}
+
+// IsRevisionDeployed tells if a material revision or an upstream pipeline label reached the pipeline,
+// and a run of the pipeline built from it passed all its stages.
+func (vsm ValueStreamMap) IsRevisionDeployed(revision string, pipeline string) bool {
+	target, ok := vsm.Node(pipeline)
+	if !ok || !target.hasPassedInstance() {
+		return false
+	}
+
+	for _, node := range vsm.Nodes {
+		if !node.carries(revision) {
+			continue
+		}
+		for _, downstream := range vsm.Downstream(node.ID) {
+			if downstream.ID == target.ID {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (vsm ValueStreamMap) traverse(id string, next func(ValueStreamNode) []string) (reached []ValueStreamNode) {
+	start, ok := vsm.Node(id)
+	if !ok {
+		return nil
+	}
+
+	visited := map[string]bool{id: true}
+	queue := next(start)
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+
+		node, ok := vsm.Node(current)
+		if !ok {
+			continue
+		}
+		reached = append(reached, node)
+		queue = append(queue, next(node)...)
+	}
+
+	return
+}
+
+func (node ValueStreamNode) carries(revision string) bool {
+	for _, materialRevision := range node.MaterialRevisions {
+		for _, modification := range materialRevision.Modifications {
+			if modification.Revision == revision {
+				return true
+			}
+		}
+	}
+	for _, instance := range node.Instances {
+		if instance.Label == revision {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (node ValueStreamNode) hasPassedInstance() bool {
+	for _, instance := range node.Instances {
+		if len(instance.Stages) == 0 {
+			continue
+		}
+
+		allPassed := true
+		for _, stage := range instance.Stages {
+			if !strings.EqualFold(stage.Status, passed) {
+				allPassed = false
+			}
+		}
+		if allPassed {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,177 @@
+// value_stream_map_test.go
+//
+// Author::    Chirantan Mitra
+// Copyright:: Copyright (c) 2015-2017. All rights reserved
+// License::   MIT
+
+package gocd_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/chiku/gocd"
+)
+
+const valueStreamMapJSON = `{
+	"current_pipeline": "Build",
+	"levels": [{
+		"nodes": [{
+			"id": "git-fingerprint",
+			"name": "https://example.com/repo.git",
+			"node_type": "GIT",
+			"depth": 1,
+			"parents": [],
+			"dependents": ["Build"],
+			"material_revisions": [{ "modifications": [{ "revision": "9f8e7d", "user": "dev", "comment": "Change" }] }]
+		}]
+	}, {
+		"nodes": [{
+			"id": "Build",
+			"name": "Build",
+			"node_type": "PIPELINE",
+			"depth": 1,
+			"parents": ["git-fingerprint"],
+			"dependents": ["Staging", "Docs"],
+			"instances": [{ "counter": 12, "label": "1.12", "stages": [{ "name": "Compile", "status": "Passed" }] }]
+		}]
+	}, {
+		"nodes": [{
+			"id": "Staging",
+			"name": "Staging",
+			"node_type": "PIPELINE",
+			"depth": 1,
+			"parents": ["Build"],
+			"dependents": ["Prod"],
+			"instances": [{ "counter": 4, "label": "4", "stages": [{ "name": "Deploy", "status": "Passed" }] }]
+		}, {
+			"id": "Docs",
+			"name": "Docs",
+			"node_type": "PIPELINE",
+			"depth": 2,
+			"parents": ["Build"],
+			"dependents": [],
+			"instances": [{ "counter": 2, "label": "2", "stages": [{ "name": "Publish", "status": "Failed" }] }]
+		}]
+	}, {
+		"nodes": [{
+			"id": "Prod",
+			"name": "Prod",
+			"node_type": "PIPELINE",
+			"depth": 1,
+			"parents": ["Staging"],
+			"dependents": [],
+			"instances": []
+		}]
+	}]
+}`
+
+func nodeIDs(nodes []gocd.ValueStreamNode) (ids []string) {
+	for _, node := range nodes {
+		ids = append(ids, node.ID)
+	}
+	return
+}
+
+func TestClientValueStreamMap(t *testing.T) {
+	var path string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Write([]byte(valueStreamMapJSON))
+	}))
+	defer ts.Close()
+
+	client := gocd.NewClient()
+	vsm, err := client.ValueStreamMap(ts.URL, "Build", 12)
+
+	if err != nil {
+		t.Fatalf("Expected no error fetching value stream map: %s", err)
+	}
+	if path != "/go/pipelines/value_stream_map/Build/12.json" {
+		t.Errorf("Expected proper value stream map path, but was: %s", path)
+	}
+	if vsm.CurrentPipeline != "Build" || len(vsm.Nodes) != 5 {
+		t.Fatalf("Expected 5 nodes around Build, but was: %#v", vsm)
+	}
+
+	expectedEdges := []gocd.ValueStreamEdge{
+		{From: "git-fingerprint", To: "Build"},
+		{From: "Build", To: "Staging"},
+		{From: "Build", To: "Docs"},
+		{From: "Staging", To: "Prod"},
+	}
+	if !reflect.DeepEqual(vsm.Edges, expectedEdges) {
+		t.Errorf("Expected dependency edges (%#v != %#v)", vsm.Edges, expectedEdges)
+	}
+
+	build, ok := vsm.Node("Build")
+	expectedInstances := []gocd.ValueStreamInstance{{Counter: 12, Label: "1.12", Stages: []gocd.ValueStreamStage{{Name: "Compile", Status: "Passed"}}}}
+	if !ok || !reflect.DeepEqual(build.Instances, expectedInstances) {
+		t.Errorf("Expected Build node with its instances, but was: %#v", build)
+	}
+}
+
+func TestValueStreamMapTraversal(t *testing.T) {
+	vsm, err := gocd.NewValueStreamMap([]byte(valueStreamMapJSON))
+	if err != nil {
+		t.Fatalf("Expected no error decoding value stream map: %s", err)
+	}
+
+	downstream := nodeIDs(vsm.Downstream("git-fingerprint"))
+	if !reflect.DeepEqual(downstream, []string{"Build", "Staging", "Docs", "Prod"}) {
+		t.Errorf("Expected all downstream nodes nearest first, but was: %v", downstream)
+	}
+
+	upstream := nodeIDs(vsm.Upstream("Prod"))
+	if !reflect.DeepEqual(upstream, []string{"Staging", "Build", "git-fingerprint"}) {
+		t.Errorf("Expected all upstream nodes nearest first, but was: %v", upstream)
+	}
+
+	if vsm.Downstream("Missing") != nil {
+		t.Errorf("Expected nothing downstream of an unknown node")
+	}
+}
+
+func TestValueStreamMapIsRevisionDeployed(t *testing.T) {
+	vsm, err := gocd.NewValueStreamMap([]byte(valueStreamMapJSON))
+	if err != nil {
+		t.Fatalf("Expected no error decoding value stream map: %s", err)
+	}
+
+	scenarios := []struct {
+		revision string
+		pipeline string
+		deployed bool
+	}{
+		{"9f8e7d", "Staging", true},
+		{"1.12", "Staging", true},
+		{"9f8e7d", "Docs", false},
+		{"9f8e7d", "Prod", false},
+		{"000000", "Staging", false},
+		{"9f8e7d", "Missing", false},
+	}
+
+	for _, scenario := range scenarios {
+		deployed := vsm.IsRevisionDeployed(scenario.revision, scenario.pipeline)
+		if deployed != scenario.deployed {
+			t.Errorf("Expected revision %s deployed to %s to be %v, but was %v", scenario.revision, scenario.pipeline, scenario.deployed, deployed)
+		}
+	}
+}
+
+func TestNewValueStreamMapOnError(t *testing.T) {
+	_, err := gocd.NewValueStreamMap([]byte(`{ "error": "Pipeline 'Build' with counter '99' not found." }`))
+
+	if err == nil || err.Error() != "error fetching value stream map: Pipeline 'Build' with counter '99' not found." {
+		t.Errorf("Expected error reported by Gocd, but was: %v", err)
+	}
+
+	_, err = gocd.NewValueStreamMap([]byte(`Random`))
+
+	if err == nil || !strings.Contains(err.Error(), "error unmarshalling Gocd JSON: ") {
+		t.Errorf("Expected error message about JSON unmarshall error, but was: %v", err)
+	}
+}